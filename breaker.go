@@ -0,0 +1,62 @@
+package recached
+
+import "time"
+
+// BreakerState is the state of the circuit breaker of a cache, see WithCircuitBreaker
+type BreakerState int
+
+const (
+	// BreakerClosed lets the update loop call the update function as usual
+	BreakerClosed BreakerState = iota
+	// BreakerOpen stops the update loop from calling the update function until the cooldown has passed
+	BreakerOpen
+	// BreakerHalfOpen lets the next automatic update probe the source after the cooldown:
+	// a success closes the breaker, a failure opens it again
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// breaker counts consecutive failures of a cache, it is guarded by the mutex of the cache
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+}
+
+// state returns the state of the breaker at now
+func (b *breaker) state(now time.Time) BreakerState {
+	switch {
+	case b.failures < b.threshold:
+		return BreakerClosed
+	case now.Sub(b.openedAt) < b.cooldown:
+		return BreakerOpen
+	default:
+		return BreakerHalfOpen
+	}
+}
+
+// record updates the breaker with the result of an update at now.
+// Reaching the threshold, or failing while half-open, opens the breaker for another cooldown
+func (b *breaker) record(err error, now time.Time) {
+	if err == nil {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = now
+	}
+}
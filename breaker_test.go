@@ -0,0 +1,89 @@
+package recached
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithCircuitBreaker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	var calls int64
+	var fail atomic.Bool
+	updateFunc := func() (int64, error) {
+		n := atomic.AddInt64(&calls, 1)
+		if fail.Load() {
+			return 0, errors.New("update failed")
+		}
+		return n, nil
+	}
+
+	cache := New(ctx, time.Second, updateFunc, WithClock[int64](clock), WithCircuitBreaker[int64](2, time.Minute))
+	defer cache.Close()
+	<-clock.created
+
+	// Consecutive failures open the breaker
+	fail.Store(true)
+	advanceUpdate(t, clock, cache, time.Second)
+	if got := cache.Stats().Breaker; got != BreakerClosed {
+		t.Errorf("Breaker after 1 failure = %v, want %v", got, BreakerClosed)
+	}
+	advanceUpdate(t, clock, cache, time.Second)
+	if got := cache.Stats().Breaker; got != BreakerOpen {
+		t.Errorf("Breaker after 2 failures = %v, want %v", got, BreakerOpen)
+	}
+
+	// While open, the loop leaves the source alone, but explicit updates go through
+	if got := cache.(*reCached[int64]).breakerOpen(); !got {
+		t.Errorf("breakerOpen() = %v, want true", got)
+	}
+	before := atomic.LoadInt64(&calls)
+	_ = cache.ForceUpdate()
+	if got := atomic.LoadInt64(&calls); got != before+1 {
+		t.Errorf("updateFunc calls after ForceUpdate() with open breaker = %v, want %v", got, before+1)
+	}
+	if got := cache.Get(); got != 1 {
+		t.Errorf("Get() with open breaker = %v, want %v", got, 1)
+	}
+
+	// After the cooldown a successful probe closes it
+	fail.Store(false)
+	advanceUpdate(t, clock, cache, time.Minute)
+	if got := cache.Stats().Breaker; got != BreakerClosed {
+		t.Errorf("Breaker after successful probe = %v, want %v", got, BreakerClosed)
+	}
+}
+
+func TestBreakerState(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	updateErr := errors.New("update failed")
+	b := &breaker{threshold: 2, cooldown: time.Minute}
+
+	check := func(step string, want BreakerState) {
+		t.Helper()
+		if got := b.state(now); got != want {
+			t.Errorf("state() %s = %v, want %v", step, got, want)
+		}
+	}
+
+	b.record(updateErr, now)
+	check("after 1 failure", BreakerClosed)
+	b.record(updateErr, now)
+	check("after 2 failures", BreakerOpen)
+
+	// A failed probe opens it for another cooldown
+	now = now.Add(time.Minute)
+	check("after cooldown", BreakerHalfOpen)
+	b.record(updateErr, now)
+	check("after failed probe", BreakerOpen)
+	now = now.Add(time.Minute)
+	check("after second cooldown", BreakerHalfOpen)
+
+	b.record(nil, now)
+	check("after success", BreakerClosed)
+}
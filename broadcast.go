@@ -0,0 +1,60 @@
+package recached
+
+import "sync"
+
+// Broadcaster delivers invalidations of named caches between instances of a service, see WithBroadcaster.
+// Implementations for e.g. Redis or NATS are left to the user
+type Broadcaster interface {
+	// Publish announces that the cache with the given name has been updated
+	Publish(name string)
+	// Subscribe returns a channel receiving the names published by all instances, including this one,
+	// and a function to unsubscribe, which the cache calls once it stops listening, e.g. on Close
+	Subscribe() (<-chan string, func())
+}
+
+// broadcastBuffer is the channel buffer of every MemoryBroadcaster subscriber
+const broadcastBuffer = 64
+
+// MemoryBroadcaster is a Broadcaster within a single process, e.g. for tests.
+// Names are dropped for subscribers whose buffer is full, so a slow subscriber never blocks Publish
+type MemoryBroadcaster struct {
+	mu    sync.Mutex
+	chans map[chan string]struct{}
+}
+
+// NewMemoryBroadcaster returns an empty MemoryBroadcaster
+func NewMemoryBroadcaster() *MemoryBroadcaster {
+	return &MemoryBroadcaster{}
+}
+
+func (b *MemoryBroadcaster) Publish(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.chans {
+		select {
+		case ch <- name:
+		default:
+		}
+	}
+}
+
+func (b *MemoryBroadcaster) Subscribe() (<-chan string, func()) {
+	ch := make(chan string, broadcastBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.chans == nil {
+		b.chans = make(map[chan string]struct{})
+	}
+	b.chans[ch] = struct{}{}
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.chans[ch]; ok {
+			delete(b.chans, ch)
+			close(ch)
+		}
+	}
+}
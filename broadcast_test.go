@@ -0,0 +1,64 @@
+package recached
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithBroadcaster(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Two instances of the same named cache, as if in different processes
+	b := NewMemoryBroadcaster()
+	var source atomic.Int64
+	source.Store(1)
+	updateFunc := func() (int64, error) {
+		return source.Load(), nil
+	}
+	opts := []Option[int64]{WithName[int64]("shared"), WithoutGlobalRegistration[int64](), WithBroadcaster[int64](b)}
+	first := New(ctx, time.Hour, updateFunc, opts...)
+	defer first.Close()
+	second := New(ctx, time.Hour, updateFunc, opts...)
+	defer second.Close()
+
+	waitFor := func(cache ReCached[int64], want int64) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for cache.Get() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for Get() = %v, got %v", want, cache.Get())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// An explicit update on one instance updates the other
+	source.Store(2)
+	first.Update()
+	waitFor(second, 2)
+
+	// So does Invalidate
+	source.Store(3)
+	second.Invalidate()
+	waitFor(first, 3)
+	waitFor(second, 3)
+
+	// Closing an instance unsubscribes it from the broadcaster
+	first.Close()
+	second.Close()
+	subscribed := func() int {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return len(b.chans)
+	}
+	deadline := time.Now().Add(time.Second)
+	for subscribed() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Subscriptions after Close() = %v, want 0", subscribed())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
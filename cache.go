@@ -2,91 +2,1629 @@ package recached
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// ReCached is a cache that can be refreshed
+// errNotModified is returned by the update function of NewConditional when there is no new value
+var errNotModified = errors.New("recached: not modified")
+
+// errDropped is returned by refresh when it dropped a new value because of WithTryLock
+var errDropped = errors.New("recached: update dropped")
+
+var (
+	// ErrPanic is returned when the update function panics
+	ErrPanic = errors.New("recached: update function panicked")
+	// ErrClosed is returned when updating a closed cache
+	ErrClosed = errors.New("recached: cache is closed")
+	// ErrStale is returned when the value is older than allowed by WithMaxStaleness
+	ErrStale = errors.New("recached: value is stale")
+	// ErrNotReady is returned when no update has succeeded yet, so the value is not loaded
+	ErrNotReady = errors.New("recached: value is not loaded yet")
+	// ErrRejected is returned when an update returns a value rejected by WithReject or WithRejectZero
+	ErrRejected = errors.New("recached: update returned a rejected value")
+	// ErrBreakerOpen is returned by HealthCheck while the circuit breaker is open, see WithCircuitBreaker
+	ErrBreakerOpen = errors.New("recached: circuit breaker is open")
+	// ErrThrottled is returned by ForceUpdate when it is called too soon after the last update, see WithMinInterval
+	ErrThrottled = errors.New("recached: update throttled")
+	// ErrExpired is returned when the value is older than its time to live, see WithTTL
+	ErrExpired = errors.New("recached: value has expired")
+)
+
+// ReCached is a cache that can be refreshed.
+// The update function is never called while holding the lock guarding the value, so reads like Get,
+// GetWithError, GetFresh of a fresh value, Stats or HealthCheck do not wait for a slow update
+// and keep returning the previous value until the update stores the new one
 type ReCached[T any] interface {
 	Get() T
+	// GetWithError returns the cached value and the error of the last update attempt, if it failed.
+	// Until an update has succeeded the error is ErrNotReady
+	GetWithError() (T, error)
+	// GetOK is like Get, but also reports whether the value is fresh: the cache is ready, the value
+	// has not expired, see WithTTL, and is not stale, see WithMaxStaleness. It is a lighter GetWithMeta
+	GetOK() (T, bool)
+	// GetFresh returns the cached value if it was updated at most maxAge ago, otherwise it updates
+	// the value synchronously first. If the update fails, the old value is returned with the error.
+	// Concurrent calls share a single update
+	GetFresh(maxAge time.Duration) (T, error)
+	// GetSWR returns the cached value right away like Get. If it was updated more than softAge ago,
+	// it also starts an update in the background, only one at a time however many callers find it old
+	GetSWR(softAge time.Duration) T
+	// Version returns the version of the value, which is incremented every time the value is replaced
+	// by an update or Set. Updates returning an equal value keep the version, see WithEqual
+	Version() uint64
+	// GetVersioned is like Get, but also returns the version of the returned value
+	GetVersioned() (T, uint64)
+	// GetWithMeta is like Get, but also returns a consistent snapshot of the state of the value
+	GetWithMeta() (T, Meta)
+	// LastUpdated returns the time of the last successful update
+	LastUpdated() time.Time
+	// Age returns the time passed since the last successful update
+	Age() time.Duration
+	// Ready reports whether at least one update has succeeded
+	Ready() bool
+	// WaitReady blocks until the cache is ready or ctx is done.
+	// It starts the first load of a cache created with WithLazy in the background, like Get would
+	WaitReady(ctx context.Context) error
+	// DebugString describes the state of the cache in one line for log messages,
+	// e.g. "recached[users] age=12s v=7 ready=true lastErr=<nil>"
+	DebugString() string
+	// WaitForVersion blocks until Version is at least v and returns the value like Get,
+	// e.g. to read a change made by the caller after Invalidate. If ctx is done first, it returns
+	// the current value with ctx.Err(), if the cache is closed, the current value with ErrClosed
+	WaitForVersion(ctx context.Context, v uint64) (T, error)
+	// Update updates the value synchronously. If an update is already in flight, it waits for that one
+	// instead of starting another. Either way, once Update returns, Get in the same goroutine
+	// observes the result of an update that completed after Update was called, unless a later one replaced it
 	Update()
+	// Invalidate makes the update loop update the value now and start a new interval,
+	// without waiting for the update. It does nothing if such an update is already pending.
+	// With WithBroadcaster the other instances are invalidated too
+	Invalidate()
+	// ForceUpdate updates the value synchronously and returns the error of the update function,
+	// ErrClosed if the cache is closed, or ErrThrottled if the update was dropped, see WithMinInterval
+	ForceUpdate() error
+	// Set replaces the cached value without calling the update function
+	Set(value T)
+	// Reset clears the value, its version and timestamp, so the cache is not ready until the next
+	// successful update. A lazy cache loads the value again on the next read, see WithLazy.
+	// The update loop keeps running
+	Reset()
+	// SetPeriod changes the interval between automatic updates, starting a new interval immediately.
+	// Non-positive durations are ignored
+	SetPeriod(d time.Duration)
+	// Period returns the current period of automatic updates, see SetPeriod and WithAdaptivePeriod
+	Period() time.Duration
+	// NextRefresh estimates when the next automatic update starts, from the last tick and the current
+	// interval including jitter and backoff. It is zero if there are no automatic updates,
+	// because the period is not positive, the cache is paused or closed or its context is done
+	NextRefresh() time.Time
+	// SetUpdateFunc replaces the update function, e.g. to fail over to another endpoint, keeping
+	// the value and the update loop. Updates starting afterwards call fn, a running one is not affected.
+	// fn replaces the function of any constructor, e.g. the one receiving the value of NewDelta
+	SetUpdateFunc(fn func() (T, error))
+	// Pause stops automatic updates until Resume is called. Explicit updates keep working
+	Pause()
+	// Resume restarts automatic updates with a full period
+	Resume()
+	// Stats returns the update statistics of the cache
+	Stats() Stats
+	// HealthCheck returns nil if the cache has a value that is neither stale nor expired
+	// and the circuit breaker is not open, see WithMaxStaleness, WithTTL and WithCircuitBreaker.
+	// Otherwise it returns ErrNotReady, ErrExpired, ErrStale or ErrBreakerOpen, including the last update error
+	HealthCheck() error
+	// DependsOn makes the cache depend on upstream: every update changing the value of upstream,
+	// like those calling WithOnUpdate, invalidates this cache, see Invalidate. Closing either cache
+	// removes the dependency. It returns ErrCycle if upstream is this cache or depends on it,
+	// and ErrClosed if this cache is closed
+	DependsOn(upstream Upstream) error
+	// Upstream lets other caches depend on this one
+	Upstream
+	// Subscribe returns a channel receiving the new value after every successful update that changed it,
+	// and a function to unsubscribe. Values are dropped while the channel is full, so a slow
+	// subscriber never blocks updates. The channel is closed on unsubscribe or Close
+	Subscribe() (<-chan T, func())
+	// SubscribeWith is like Subscribe, but with the buffer and the policy for a full buffer set by opts.
+	// With Block no value is lost, but a slow subscriber delays the update until it catches up.
+	// A blocked send gives up on unsubscribe or Close, so neither waits for the subscriber
+	SubscribeWith(opts SubscribeOptions) (<-chan T, func())
+	// Errors returns a channel receiving the error of every failed update, prefixed with the cache, see WithName.
+	// Errors are dropped while the channel is full, so a slow reader never blocks updates.
+	// All calls return the same channel, which is closed by Close
+	Errors() <-chan error
+	// Close stops automatic updates and removes the cache from the global registry.
+	// The last value stays available via Get, also to readers racing Close: it is never zeroed,
+	// and the result of an update still running, e.g. cancelled by Close, is dropped
+	Close()
+}
+
+// Stats describes the update history of a cache
+type Stats struct {
+	// Updates is the number of calls of the update function, the retries of WithUpdateRetry count as one
+	Updates uint64
+	// Failures is the number of calls of the update function that returned an error, after the retries
+	Failures uint64
+	// ConsecutiveFailures is the number of calls of the update function that failed since the last successful one
+	ConsecutiveFailures int
+	// FailingSince is the time of the first of the consecutive failures, zero if the last call succeeded
+	FailingSince time.Time
+	// Throttled is the number of explicit updates dropped because of WithMinInterval
+	Throttled uint64
+	// LastDuration is the duration of the last call of the update function
+	LastDuration time.Duration
+	// LastSuccess is the time of the last successful call of the update function.
+	// Unlike LastUpdated it also moves when the value is unchanged, see WithEqual
+	LastSuccess time.Time
+	// LastError is the error of the last update, nil if it succeeded
+	LastError error
+	// Backoff is the current interval of the update loop while backing off after failures, see WithBackoff.
+	// It is zero when the loop is not backing off
+	Backoff time.Duration
+	// Breaker is the state of the circuit breaker, always BreakerClosed without WithCircuitBreaker
+	Breaker BreakerState
+	// Source is the source of the last successful update: 0 for the update function
+	// and i for the i-th fallback, see WithFallback
+	Source int
+	// Period is the current period of automatic updates, see SetPeriod and WithAdaptivePeriod
+	Period time.Duration
+	// OverlappingUpdates is the number of automatic updates that took longer than the interval,
+	// so the next one was due before they finished. It is then started right after them
+	OverlappingUpdates uint64
+	// SkippedTicks is the number of automatic updates dropped because they were due while
+	// a longer running one was in progress, beyond the one started right after it,
+	// and of those whose result was dropped because of WithTryLock
+	SkippedTicks uint64
+}
+
+// Meta describes the state of the value returned by GetWithMeta
+type Meta struct {
+	// LastUpdated is the time of the last successful update, see ReCached.LastUpdated
+	LastUpdated time.Time
+	// Version is the version of the value, see ReCached.Version
+	Version uint64
+	// LastError is the error of the last update, nil if it succeeded
+	LastError error
+	// Stale reports whether the value is stale or has expired, see WithMaxStaleness and WithTTL
+	Stale bool
+}
+
+// ErrorAction tells a cache what to do with its value when an update fails, see WithErrorHandler
+type ErrorAction int
+
+const (
+	// KeepValue keeps serving the previous value, which is the default
+	KeepValue ErrorAction = iota
+	// ClearValue drops the value, so Get returns the zero value and GetWithError the zero value
+	// with the error, e.g. for data that must not be served once it cannot be refreshed.
+	// Like Reset, it makes the cache not ready until the next successful update
+	ClearValue
+	// Retry calls the update function again right away. The handler is asked again if the retry
+	// fails too, but another Retry is then treated as KeepValue
+	Retry
+)
+
+func (a ErrorAction) String() string {
+	switch a {
+	case KeepValue:
+		return "keep"
+	case ClearValue:
+		return "clear"
+	case Retry:
+		return "retry"
+	default:
+		return "unknown"
+	}
 }
 
+// errorBuffer is the channel buffer of Errors
+const errorBuffer = 8
+
 type reCached[T any] struct {
+	name       string
+	tags       []string
+	registered bool
 	mu         sync.RWMutex
 	value      T
+	current    atomic.Pointer[T] // value for lock-free reads, see Get
+	err        error
+	updatedAt  time.Time
+	stats      Stats
+	sets       uint64
+	version    uint64
+	ready      bool
+	readyCh    chan struct{}
+	versionCh  chan struct{} // closed on the next version, nil without waiters, see WaitForVersion
+	closed     bool
+	paused     bool
 	period     time.Duration
-	updateFunc func() (T, error)
+	adaptive   time.Duration // current period with WithAdaptivePeriod
+	minPeriod  time.Duration
+	maxPeriod  time.Duration
+	resetCh    chan struct{}
+	trigger    <-chan struct{}
+	invalidCh  chan struct{}
+	updateFunc func(ctx context.Context) (T, error)
+	fallbacks  []func(ctx context.Context) (T, error)
+	timeout    time.Duration
+	initTime   time.Duration // timeout of the initial update, see WithInitialTimeout
+	initial    bool          // set during the initial update, before the loop starts
+	throttle   time.Duration
+	jitter     float64
+	maxBackoff time.Duration
+	backoff    time.Duration
+	breaker    *breaker // nil without a circuit breaker
+	onUpdate   func(old, new T)
+	onStart    func(ctx context.Context) context.Context
+	onEnd      func(ctx context.Context, err error)
+	onError    func(err error) ErrorAction
+	onSlow     func(d time.Duration)
+	slowAfter  time.Duration
+	subs       subscribers[T]
+	deps       edges // see DependsOn, guarded by dependencyMu
+	errs       chan error
+	broadcast  Broadcaster
+	file       *persistence[T]
+	codec      Codec[T]     // used by GlobalSnapshot, see WithCodec
+	seen       *extremes[T] // nil unless created by NewOrdered
+	equal      func(a, b T) bool
+	copy       func(T) T
+	through    func() (T, bool)
+	reject     func(T) bool
+	swr        atomic.Bool // set while GetSWR updates in the background
+	flight     flight
+	logger     Logger  // nil if logging is disabled
+	metrics    Metrics // nil without WithMetrics
+	clock      Clock
+	retries    int
+	retryDelay time.Duration
+	retry      RetryPolicy
+	singleton  bool
+	contended  atomic.Uint64 // automatic updates dropped because of WithTryLock
+	nextTick   atomic.Int64  // in Unix nanoseconds, 0 without automatic updates, see NextRefresh
+	lazy       bool
+	lazyNoWait bool
+	immediate  bool
+	tryLock    bool
+	lazyLoad   *sync.Once // replaced by Reset, guarded by mu
+	// Staleness
+	maxStaleness time.Duration
+	zeroOnStale  bool
+	ttl          time.Duration
+	refreshAhead time.Duration
+	ctx          context.Context
+	cancel       context.CancelFunc
 }
 
-// Global registry to keep track of all cache instances
-var (
-	globalCachesMutex sync.RWMutex
-	globalCaches      []interface{ Update() }
-)
+// New creates a cache, loads the initial value and starts updating it every period.
+// A period of 0 or less means no automatic updates, the cache is then updated explicitly only.
+// The cache is started even if the initial update fails.
+// It panics if a cache with the same name is already registered, see WithName
+func New[T any](ctx context.Context, period time.Duration, updateFunc func() (T, error), opts ...Option[T]) ReCached[T] {
+	cache, err := newReCached(ctx, period, ignoreContext(updateFunc), opts...).run(false)
+	if err != nil {
+		panic(err)
+	}
 
-func New[T any](ctx context.Context, period time.Duration, updateFunc func() (T, error)) ReCached[T] {
+	return cache
+}
+
+// NewOrError is like New, but returns the error of the initial update.
+// The cache is started and registered only when the initial update succeeds.
+// With WithLazy there is no initial update, so no error is returned for it.
+// A name that is already registered is returned as an error as well
+func NewOrError[T any](ctx context.Context, period time.Duration, updateFunc func() (T, error), opts ...Option[T]) (ReCached[T], error) {
+	cache, err := newReCached(ctx, period, ignoreContext(updateFunc), opts...).run(true)
+	if err != nil {
+		return nil, err
+	}
+
+	return cache, nil
+}
+
+// NewNamed is like New, but registers the cache under name, see LookupCache.
+// It panics if a cache with the same name is already registered
+func NewNamed[T any](ctx context.Context, name string, period time.Duration, updateFunc func() (T, error), opts ...Option[T]) ReCached[T] {
+	return New(ctx, period, updateFunc, append(opts, WithName[T](name))...)
+}
+
+// NewWithCancel is like New, but the cache runs in a child context of parent, which is cancelled
+// by the returned function. Cancelling stops automatic updates and removes the cache from the global
+// registry right away, explicit updates keep working like with a cancelled context passed to New
+func NewWithCancel[T any](parent context.Context, period time.Duration, updateFunc func() (T, error), opts ...Option[T]) (ReCached[T], context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	cache, err := newReCached(ctx, period, ignoreContext(updateFunc), opts...).run(false)
+	if err != nil {
+		cancel()
+		panic(err)
+	}
+
+	return cache, func() {
+		cancel()
+		// A cache returned by WithSingleton may run in another context, then it stays registered
+		if cache.done() {
+			deregister(cache)
+		}
+	}
+}
+
+// NewWithFallback is like New, but if primary fails the value is loaded from fallback instead,
+// see WithFallback
+func NewWithFallback[T any](ctx context.Context, period time.Duration, primary, fallback func() (T, error), opts ...Option[T]) ReCached[T] {
+	return New(ctx, period, primary, append([]Option[T]{WithFallback(fallback)}, opts...)...)
+}
+
+// NewDelta is like New, but updateFunc receives the currently cached value, so it can fetch only
+// what changed since then. The zero value is passed until a value is loaded, e.g. by the first update
+func NewDelta[T any](ctx context.Context, period time.Duration, updateFunc func(prev T) (T, error), opts ...Option[T]) ReCached[T] {
+	cache := newReCached[T](ctx, period, nil, opts...)
+	cache.updateFunc = func(context.Context) (T, error) {
+		cache.mu.RLock()
+		prev := cache.value
+		cache.mu.RUnlock()
+		return updateFunc(prev)
+	}
+
+	started, err := cache.run(false)
+	if err != nil {
+		panic(err)
+	}
+
+	return started
+}
+
+// NewConditional is like New, but updateFunc reports whether it returns a value at all,
+// e.g. false for an HTTP 304 Not Modified response. Without a value the cached one is kept:
+// LastUpdated moves, since the value has been confirmed, but the version does not change
+// and the OnUpdate callback and subscribers are not notified
+func NewConditional[T any](ctx context.Context, period time.Duration, updateFunc func() (T, bool, error), opts ...Option[T]) ReCached[T] {
+	cache, err := newReCached(ctx, period, func(context.Context) (T, error) {
+		value, ok, err := updateFunc()
+		if err == nil && !ok {
+			err = errNotModified
+		}
+		return value, err
+	}, opts...).run(false)
+	if err != nil {
+		panic(err)
+	}
+
+	return cache
+}
+
+// NewCtx is like New, but updateFunc receives a context derived from ctx.
+// The context is cancelled when the cache is closed or the call exceeds the timeout set by WithTimeout
+func NewCtx[T any](ctx context.Context, period time.Duration, updateFunc func(ctx context.Context) (T, error), opts ...Option[T]) ReCached[T] {
+	cache, err := newReCached(ctx, period, updateFunc, opts...).run(false)
+	if err != nil {
+		panic(err)
+	}
+
+	return cache
+}
+
+func newReCached[T any](ctx context.Context, period time.Duration, updateFunc func(ctx context.Context) (T, error), opts ...Option[T]) *reCached[T] {
+	ctx, cancel := context.WithCancel(ctx)
 	cache := &reCached[T]{
 		period:     period,
+		resetCh:    make(chan struct{}, 1),
+		invalidCh:  make(chan struct{}, 1),
+		errs:       make(chan error, errorBuffer),
+		registered: true,
+		clock:      realClock{},
+		logger:     currentDefaultLogger(),
 		updateFunc: updateFunc,
+		readyCh:    make(chan struct{}),
+		lazyLoad:   new(sync.Once),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	for _, opt := range opts {
+		opt(cache)
 	}
+	return cache
+}
 
-	cache.Update()
-	go cache.updateLoop(ctx)
+func ignoreContext[T any](updateFunc func() (T, error)) func(ctx context.Context) (T, error) {
+	return func(context.Context) (T, error) {
+		return updateFunc()
+	}
+}
 
-	// Register the cache in the global registry
-	globalCachesMutex.Lock()
-	globalCaches = append(globalCaches, cache)
-	globalCachesMutex.Unlock()
+// run performs the initial update, unless the cache is lazy, and starts the cache.
+// The name is checked before the initial update, so a duplicate name does not call updateFunc.
+// With strict set, a failed initial update is returned and the cache is not started.
+// It returns the started cache, which is an existing one for WithSingleton
+func (r *reCached[T]) run(strict bool) (*reCached[T], error) {
+	if r.singleton {
+		// Held until the cache is registered, so concurrent constructors cannot both miss the existing one
+		singletonMu.Lock()
+		defer singletonMu.Unlock()
 
-	return cache
+		if existing, ok := lookup(r.name); ok {
+			r.cancel()
+			cache, ok := existing.(*reCached[T])
+			if !ok {
+				return nil, fmt.Errorf("recached: cache %q is registered with a different value type", r.name)
+			}
+			return cache, nil
+		}
+	}
+
+	if r.registered {
+		if err := checkName(r.name); err != nil {
+			r.cancel()
+			return nil, err
+		}
+	}
+
+	r.loadPersisted()
+
+	if !r.lazy {
+		if err := r.initialUpdate(); err != nil && strict {
+			r.cancel()
+			return nil, err
+		}
+	}
+
+	if err := r.start(); err != nil {
+		return nil, err
+	}
+	return r, nil
 }
 
+// initialUpdate performs the initial update, retried as configured by WithInitialRetry.
+// It returns the error of the last attempt. If the context is already done,
+// updateFunc is not called at all and the cache stays not ready
+func (r *reCached[T]) initialUpdate() error {
+	if err := r.ctx.Err(); err != nil {
+		return err
+	}
+	// Nothing else uses the cache before it is started, so the flag needs no lock
+	r.initial = true
+	defer func() { r.initial = false }()
+
+	err := r.update()
+	for attempt := 1; err != nil && attempt < r.retries; attempt++ {
+		r.log("%s: initial update failed, retrying in %v: %v", r, r.retryDelay, err)
+
+		timer := time.NewTimer(r.retryDelay)
+		select {
+		case <-timer.C:
+		case <-r.ctx.Done():
+			timer.Stop()
+			return err
+		}
+		err = r.update()
+	}
+	return err
+}
+
+// start registers the cache in the global registry, unless opted out, and runs the update loop.
+// It fails if another registered cache has the same name
+func (r *reCached[T]) start() error {
+	if r.registered {
+		if err := register(r); err != nil {
+			r.cancel()
+			return err
+		}
+	}
+	if r.broadcast != nil && r.name != "" {
+		names, unsubscribe := r.broadcast.Subscribe()
+		go r.listen(r.ctx, names, unsubscribe)
+	}
+	go r.updateLoop(r.ctx)
+	return nil
+}
+
+// updateLoop updates the cache on a ticker, so ticks happen at fixed intervals regardless of how long
+// an update takes. Ticks missed during a slow update are coalesced into one, not queued.
+// The ticker is only reset when the interval changes because of jitter or backoff.
+// A cache without a positive period is updated on Invalidate and its trigger only, see WithTrigger
 func (r *reCached[T]) updateLoop(ctx context.Context) {
+	interval := r.interval()
+	ticker := r.clock.NewTicker(tickerInterval(interval))
+	defer ticker.Stop()
+	tick := ticker.C()
+	if interval <= 0 {
+		ticker.Stop()
+		tick = nil
+	}
+	r.setNextTick(r.clock.Now(), interval)
+	trigger := r.trigger
+
+	// Without WithLazy the initial update has just loaded the value, unless it failed
+	if r.immediate && ctx.Err() == nil && !r.Ready() {
+		r.backoffAfter(r.tickUpdate())
+		if next := r.interval(); next != interval {
+			interval = next
+			tick = r.reschedule(ticker, interval)
+		}
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
+			// A cache with a cancelled context takes no part in global updates anymore,
+			// but explicit updates keep working
+			deregister(r)
 			return
-		case <-time.After(r.period):
-			r.Update()
+		case at := <-tick:
+			// The ticker keeps its interval, so the next tick is due one interval after this one
+			r.setNextTick(at, interval)
+			if r.isPaused() || r.breakerOpen() {
+				continue
+			}
+			start := r.clock.Now()
+			r.backoffAfter(r.tickUpdate())
+			r.countOverlap(r.clock.Now().Sub(start), interval)
+			if next := r.interval(); next != interval {
+				interval = next
+				tick = r.reschedule(ticker, interval)
+			}
+		case <-r.resetCh:
+			interval = r.interval()
+			tick = r.reschedule(ticker, interval)
+		case _, ok := <-trigger:
+			if !ok {
+				// A closed trigger would fire forever
+				trigger = nil
+				continue
+			}
+			if r.isPaused() || r.breakerOpen() {
+				continue
+			}
+			r.backoffAfter(r.tickUpdate())
+			interval = r.interval()
+			tick = r.reschedule(ticker, interval)
+		case <-r.invalidCh:
+			// Explicitly requested, so neither a pause nor the circuit breaker apply
+			r.backoffAfter(r.update())
+			interval = r.interval()
+			tick = r.reschedule(ticker, interval)
 		}
 	}
 }
 
+// countOverlap records an automatic update that took longer than interval, see Stats.OverlappingUpdates.
+// The ticker keeps one tick while the update runs and drops the others, which are counted as skipped
+func (r *reCached[T]) countOverlap(elapsed, interval time.Duration) {
+	if elapsed <= interval {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.OverlappingUpdates++
+	r.stats.SkippedTicks += uint64(elapsed/interval) - 1
+}
+
+// schedule resets ticker to interval and returns its channel.
+// A non-positive interval means no automatic updates, then the ticker is stopped and the channel is nil
+func schedule(ticker Ticker, interval time.Duration) <-chan time.Time {
+	if interval <= 0 {
+		ticker.Stop()
+		return nil
+	}
+	ticker.Reset(interval)
+	return ticker.C()
+}
+
+// reschedule is schedule, recording when the ticker fires next, see NextRefresh
+func (r *reCached[T]) reschedule(ticker Ticker, interval time.Duration) <-chan time.Time {
+	tick := schedule(ticker, interval)
+	r.setNextTick(r.clock.Now(), interval)
+	return tick
+}
+
+// setNextTick records that the ticker fires one interval after from, or never if interval is not positive.
+// It does not take r.mu, so the loop never waits for readers holding it, see WithTryLock
+func (r *reCached[T]) setNextTick(from time.Time, interval time.Duration) {
+	if interval <= 0 {
+		r.nextTick.Store(0)
+		return
+	}
+	r.nextTick.Store(from.Add(interval).UnixNano())
+}
+
+// tickerInterval makes d usable for a ticker, which does not accept non-positive durations
+func tickerInterval(d time.Duration) time.Duration {
+	return max(d, time.Nanosecond)
+}
+
+// interval returns the time to wait before the next automatic update,
+// or 0 if there are no automatic updates because the period is not positive
+func (r *reCached[T]) interval() time.Duration {
+	r.mu.RLock()
+	period := r.periodLocked()
+	backoff := r.backoff
+	due, ahead := r.refreshDueLocked()
+	r.mu.RUnlock()
+
+	if period <= 0 {
+		return 0
+	}
+
+	if backoff > 0 {
+		period = backoff
+	}
+	if r.jitter != 0 {
+		period = time.Duration(float64(period) * (1 + r.jitter*(2*rand.Float64()-1)))
+	}
+	// Refreshing ahead must not be delayed by jitter, the value would expire
+	if ahead && backoff == 0 {
+		period = min(period, due)
+	}
+	return tickerInterval(period)
+}
+
+// refreshDueLocked returns the time left until the value has to be refreshed ahead of going stale
+// or expiring, whichever comes first, see WithRefreshAhead, r.mu must be held.
+// It reports false if there is nothing to refresh ahead, including when the refresh
+// is overdue because it failed, then the regular period applies
+func (r *reCached[T]) refreshDueLocked() (time.Duration, bool) {
+	limit := r.maxStaleness
+	if r.ttl > 0 && (limit <= 0 || r.ttl < limit) {
+		limit = r.ttl
+	}
+	if r.refreshAhead <= 0 || limit <= 0 || !r.ready {
+		return 0, false
+	}
+	due := r.freshAtLocked().Add(limit - r.refreshAhead).Sub(r.clock.Now())
+	return due, due > 0
+}
+
+// periodLocked returns the period of automatic updates, which is adapted with WithAdaptivePeriod, r.mu must be held
+func (r *reCached[T]) periodLocked() time.Duration {
+	if r.maxPeriod > 0 && r.period > 0 {
+		return r.adaptive
+	}
+	return r.period
+}
+
+// backoffAfter grows or resets the backoff interval depending on the result of an automatic update
+func (r *reCached[T]) backoffAfter(err error) {
+	if r.maxBackoff <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case err == nil:
+		r.backoff = 0
+	case r.backoff == 0:
+		r.backoff = r.period
+	default:
+		r.backoff = max(min(2*r.backoff, r.maxBackoff), r.period)
+	}
+}
+
 func (r *reCached[T]) Get() T {
+	r.ensureLoaded()
+	if value, ok := r.readThrough(); ok {
+		return value
+	}
+
+	// Without expiry the value is all Get needs, so it is read without taking the lock
+	if r.ttl <= 0 && !r.zeroOnStale {
+		if value := r.current.Load(); value != nil {
+			return r.copied(*value)
+		}
+		var zero T
+		return zero
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.getLocked()
+}
+
+func (r *reCached[T]) GetOK() (T, bool) {
+	r.ensureLoaded()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.getLocked(), r.ready && !r.expiredLocked() && !r.staleLocked()
+}
+
+func (r *reCached[T]) GetVersioned() (T, uint64) {
+	r.ensureLoaded()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.getLocked(), r.version
+}
+
+func (r *reCached[T]) GetWithMeta() (T, Meta) {
+	r.ensureLoaded()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.getLocked(), r.metaLocked()
+}
+
+// metaLocked returns the metadata of the value, r.mu must be held
+func (r *reCached[T]) metaLocked() Meta {
+	return Meta{
+		LastUpdated: r.updatedAt,
+		Version:     r.version,
+		LastError:   r.err,
+		Stale:       r.staleLocked() || r.expiredLocked(),
+	}
+}
+
+// getLocked returns the value for Get, r.mu must be held
+func (r *reCached[T]) getLocked() T {
+	if r.expiredLocked() || r.zeroOnStale && r.staleLocked() {
+		var zero T
+		return zero
+	}
+	return r.copied(r.value)
+}
+
+func (r *reCached[T]) Version() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.version
+}
+
+func (r *reCached[T]) GetWithError() (T, error) {
+	r.ensureLoaded()
+	if value, ok := r.readThrough(); ok {
+		return value, ErrNotReady
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if !r.ready {
+		return r.copied(r.value), r.wrapErrLocked(ErrNotReady)
+	}
+	if r.expiredLocked() {
+		var zero T
+		return zero, r.wrapErrLocked(ErrExpired)
+	}
+	if r.staleLocked() {
+		return r.copied(r.value), r.wrapErrLocked(ErrStale)
+	}
+	return r.copied(r.value), r.err
+}
+
+// readThrough returns the value of the function set by WithReadThrough while the cache is not ready.
+// It is called without the lock, since it may be slow
+func (r *reCached[T]) readThrough() (T, bool) {
+	if r.through == nil || r.Ready() {
+		var zero T
+		return zero, false
+	}
+	return r.through()
+}
+
+// copied returns a copy of value made by the function set by WithCopy, or value itself without one
+func (r *reCached[T]) copied(value T) T {
+	if r.copy == nil {
+		return value
+	}
+	return r.copy(value)
+}
+
+func (r *reCached[T]) GetFresh(maxAge time.Duration) (T, error) {
+	r.mu.RLock()
+	fresh := r.ready && r.clock.Now().Sub(r.freshAtLocked()) <= maxAge
+	value := r.value
+	r.mu.RUnlock()
+	if fresh {
+		return r.copied(value), nil
+	}
+
+	err := r.update()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.copied(r.value), err
+}
+
+func (r *reCached[T]) GetSWR(softAge time.Duration) T {
+	value := r.Get()
+
+	r.mu.RLock()
+	fresh := r.ready && r.clock.Now().Sub(r.freshAtLocked()) <= softAge
+	closed := r.closed
+	r.mu.RUnlock()
+
+	if !fresh && !closed && r.swr.CompareAndSwap(false, true) {
+		go func() {
+			defer r.swr.Store(false)
+			_ = r.update()
+		}()
+	}
+	return value
+}
+
+// wrapErrLocked returns sentinel combined with the error of the last update, if it failed, r.mu must be held
+func (r *reCached[T]) wrapErrLocked(sentinel error) error {
+	if r.err != nil {
+		return fmt.Errorf("%w: %w", sentinel, r.err)
+	}
+	return sentinel
+}
+
+// staleLocked reports whether the value is older than the maximum staleness, r.mu must be held.
+// Successful updates returning an unchanged value count as fresh
+func (r *reCached[T]) staleLocked() bool {
+	if r.maxStaleness <= 0 || !r.ready {
+		return false
+	}
+	return r.clock.Now().Sub(r.freshAtLocked()) > r.maxStaleness
+}
+
+// expiredLocked reports whether the value is older than its time to live, r.mu must be held.
+// Like for staleness, successful updates returning an unchanged value count as fresh
+func (r *reCached[T]) expiredLocked() bool {
+	if r.ttl <= 0 || !r.ready {
+		return false
+	}
+	return r.clock.Now().Sub(r.freshAtLocked()) > r.ttl
+}
+
+// freshAtLocked returns the time of the last successful update, including updates
+// returning an unchanged value, r.mu must be held
+func (r *reCached[T]) freshAtLocked() time.Time {
+	if r.stats.LastSuccess.After(r.updatedAt) {
+		return r.stats.LastSuccess
+	}
+	return r.updatedAt
+}
+
+// ensureLoaded loads the value of a lazy cache that has not been loaded yet.
+// Only the first read loads it, concurrent first reads wait for that load.
+// If it fails, later reads do not retry and leave it to the update loop, until Reset
+func (r *reCached[T]) ensureLoaded() {
+	if !r.lazy || r.Ready() {
+		return
+	}
+
+	r.mu.RLock()
+	lazyLoad := r.lazyLoad
+	r.mu.RUnlock()
+
+	lazyLoad.Do(func() {
+		// The update loop or an explicit update may have loaded the value in the meantime
+		if r.Ready() {
+			return
+		}
+		if r.lazyNoWait {
+			go r.load()
+			return
+		}
+		r.load()
+	})
+}
+
+// load is update for the first load of a lazy cache. It does nothing if the value is ready
+// once the update would start, e.g. because the update loop loaded it in the meantime
+func (r *reCached[T]) load() {
+	var notify func()
+	r.flight.do(func() (err error) {
+		if r.Ready() {
+			return nil
+		}
+		notify, err = r.refresh(context.Background(), false)
+		return err
+	})
+
+	if notify != nil {
+		notify()
+	}
+}
+
+func (r *reCached[T]) LastUpdated() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.updatedAt
+}
+
+func (r *reCached[T]) Age() time.Duration {
+	return r.clock.Now().Sub(r.LastUpdated())
+}
+
+func (r *reCached[T]) Ready() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.ready
+}
+
+func (r *reCached[T]) WaitReady(ctx context.Context) error {
+	// Nobody may call Get before the cache is ready, e.g. WarmAll at startup
+	if r.lazy && !r.Ready() {
+		go r.ensureLoaded()
+	}
+
+	r.mu.RLock()
+	readyCh := r.readyCh
+	r.mu.RUnlock()
+
+	select {
+	case <-readyCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *reCached[T]) WaitForVersion(ctx context.Context, v uint64) (T, error) {
+	for {
+		r.mu.Lock()
+		if r.version >= v {
+			defer r.mu.Unlock()
+			return r.getLocked(), nil
+		}
+		if r.closed {
+			defer r.mu.Unlock()
+			return r.getLocked(), ErrClosed
+		}
+		if r.versionCh == nil {
+			r.versionCh = make(chan struct{})
+		}
+		versionCh := r.versionCh
+		r.mu.Unlock()
+
+		select {
+		case <-versionCh:
+		case <-ctx.Done():
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.getLocked(), ctx.Err()
+		}
+	}
+}
+
+// bumpVersionLocked increments the version and wakes WaitForVersion, r.mu must be held
+func (r *reCached[T]) bumpVersionLocked() {
+	r.version++
+	if r.versionCh != nil {
+		close(r.versionCh)
+		r.versionCh = nil
+	}
+}
+
+func (r *reCached[T]) SetPeriod(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	r.period = d
+	if r.maxPeriod > 0 {
+		r.adaptive = min(max(d, r.minPeriod), r.maxPeriod)
+	}
+	r.mu.Unlock()
+
+	r.resetTicker()
+}
+
+func (r *reCached[T]) Period() time.Duration {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	return r.value
+	return r.periodLocked()
+}
+
+func (r *reCached[T]) NextRefresh() time.Time {
+	r.mu.RLock()
+	stopped := r.closed || r.paused
+	r.mu.RUnlock()
+
+	next := r.nextTick.Load()
+	if stopped || next == 0 || r.ctx.Err() != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, next)
+}
+
+func (r *reCached[T]) SetUpdateFunc(fn func() (T, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updateFunc = ignoreContext(fn)
+}
+
+func (r *reCached[T]) Pause() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused = true
+}
+
+func (r *reCached[T]) Resume() {
+	r.mu.Lock()
+	wasPaused := r.paused
+	r.paused = false
+	r.mu.Unlock()
+
+	if wasPaused {
+		r.resetTicker()
+	}
+}
+
+func (r *reCached[T]) Invalidate() {
+	r.invalidate()
+	r.publish()
+}
+
+// invalidate wakes up the update loop to update the value right away
+func (r *reCached[T]) invalidate() {
+	select {
+	case r.invalidCh <- struct{}{}:
+	default:
+	}
+}
+
+// resetTicker wakes up the update loop to start a new interval, unless it has been woken up already
+func (r *reCached[T]) resetTicker() {
+	select {
+	case r.resetCh <- struct{}{}:
+	default:
+	}
+}
+
+func (r *reCached[T]) Subscribe() (<-chan T, func()) {
+	return r.subs.subscribe(SubscribeOptions{})
+}
+
+func (r *reCached[T]) SubscribeWith(opts SubscribeOptions) (<-chan T, func()) {
+	return r.subs.subscribe(opts)
+}
+
+func (r *reCached[T]) Stats() Stats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := r.stats
+	stats.Period = r.periodLocked()
+	stats.SkippedTicks += r.contended.Load()
+	stats.LastError = r.err
+	stats.Backoff = r.backoff
+	if r.breaker != nil {
+		stats.Breaker = r.breaker.state(r.clock.Now())
+	}
+	return stats
 }
 
 func (r *reCached[T]) Update() {
-	newValue, err := r.updateFunc()
+	_ = r.ForceUpdate()
+}
+
+func (r *reCached[T]) ForceUpdate() error {
+	return r.forceUpdate(context.Background())
+}
+
+// forceUpdate is ForceUpdate, also cancelling the call of updateFunc when ctx is done, see updateContext
+func (r *reCached[T]) forceUpdate(ctx context.Context) error {
+	if r.throttled() {
+		return ErrThrottled
+	}
+	err := r.updateTry(ctx, false)
+	if err == nil {
+		r.publish()
+	}
+	return err
+}
+
+// publish tells the other instances to update the cache, if it is named and WithBroadcaster is set
+func (r *reCached[T]) publish() {
+	if r.broadcast != nil && r.name != "" {
+		r.broadcast.Publish(r.name)
+	}
+}
+
+// listen invalidates the cache whenever another instance publishes its name, until ctx is done.
+// The updates it causes are not published again, so instances do not keep invalidating each other
+func (r *reCached[T]) listen(ctx context.Context, names <-chan string, unsubscribe func()) {
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case name, ok := <-names:
+			if !ok {
+				return
+			}
+			if name == r.name {
+				r.invalidate()
+			}
+		}
+	}
+}
+
+// throttled reports whether an explicit update has to be dropped because the last successful update
+// was less than the minimum interval ago, see WithMinInterval, and counts the dropped update
+func (r *reCached[T]) throttled() bool {
+	if r.throttle <= 0 {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stats.LastSuccess.IsZero() || r.clock.Now().Sub(r.stats.LastSuccess) >= r.throttle {
+		return false
+	}
+	r.stats.Throttled++
+	return true
+}
+
+// update refreshes the value and returns the error of updateFunc.
+// Concurrent calls share a single call of updateFunc
+func (r *reCached[T]) update() error {
+	return r.updateTry(context.Background(), false)
+}
+
+// tickUpdate is update for automatic updates, which drop their result with WithTryLock
+// if the value is locked by readers, see refresh
+func (r *reCached[T]) tickUpdate() error {
+	return r.updateTry(context.Background(), r.tryLock)
+}
+
+// updateTry is update, dropping a new value instead of waiting for the lock if try is set.
+// ctx is passed to refresh, so it applies to the concurrent calls sharing the update as well.
+// A caller not setting try that joined an update dropping its value updates again, so the value is stored
+func (r *reCached[T]) updateTry(ctx context.Context, try bool) error {
+	for {
+		var notify func()
+		err := r.flight.do(func() (err error) {
+			notify, err = r.refresh(ctx, try)
+			return err
+		})
+
+		// Called outside of the flight and without the lock, so the callback may use the cache itself
+		if notify != nil {
+			notify()
+		}
+		if !errors.Is(err, errDropped) {
+			return err
+		}
+		if try {
+			return nil
+		}
+	}
+}
+
+// refresh calls updateFunc and stores its result.
+// It returns a function to notify about the change, if there was one.
+// If try is set and the lock is taken, a new value is dropped and counted as a skipped tick,
+// then it returns errDropped. Failures are recorded as usual
+func (r *reCached[T]) refresh(caller context.Context, try bool) (func(), error) {
+	r.mu.RLock()
+	closed, sets, updateFunc := r.closed, r.sets, r.updateFunc
+	r.mu.RUnlock()
+	if closed {
+		return nil, ErrClosed
+	}
+
+	ctx, cancel := r.updateContext(caller)
+	if r.onStart != nil {
+		ctx = r.onStart(ctx)
+	}
+	start := r.clock.Now()
+	newValue, source, err := r.callRetried(ctx, updateFunc)
+	action := r.handleError(err)
+	if action == Retry {
+		newValue, source, err = r.callRetried(ctx, updateFunc)
+		// Retried once only, so a handler always asking for a retry cannot hammer the source
+		if action = r.handleError(err); action == Retry {
+			action = KeepValue
+		}
+	}
+	duration := r.clock.Now().Sub(start)
+	notModified := errors.Is(err, errNotModified)
+	if notModified {
+		err = nil
+	}
+	if r.onEnd != nil {
+		r.onEnd(ctx, err)
+	}
+	cancel()
+
+	if r.onSlow != nil && duration > r.slowAfter {
+		r.onSlow(duration)
+	}
+
+	// Checked here so an unset logger costs nothing, not even boxing the arguments
+	if r.logger != nil {
+		if err != nil {
+			r.logger.Printf("%s: update failed after %v: %v", r, duration, err)
+		} else {
+			r.logger.Printf("%s: updated in %v", r, duration)
+		}
+	}
+
+	// Failures have no value to store, so they wait for the lock to be recorded
+	switch {
+	case !try || err != nil:
+		r.mu.Lock()
+	case !r.mu.TryLock():
+		r.contended.Add(1)
+		return nil, errDropped
+	}
+	// Deferred before the unlock, so it runs after it
+	if r.metrics != nil {
+		defer r.reportMetrics(err, duration)
+	}
+	defer r.mu.Unlock()
+
+	now := r.clock.Now()
+	r.stats.Updates++
+	r.stats.LastDuration = duration
+	if r.breaker != nil {
+		r.breaker.record(err, now)
+	}
 	if err != nil {
+		r.stats.Failures++
+		r.stats.ConsecutiveFailures++
+		if r.stats.FailingSince.IsZero() {
+			r.stats.FailingSince = now
+		}
+	} else {
+		r.stats.LastSuccess = now
+		r.stats.Source = source
+		r.stats.ConsecutiveFailures = 0
+		r.stats.FailingSince = time.Time{}
+	}
+
+	// The cache may have been closed while updateFunc was running
+	if r.closed {
+		return nil, ErrClosed
+	}
+
+	// A value passed to Set while updateFunc was running is newer than its result
+	if r.sets != sets {
+		return nil, nil
+	}
+
+	// Keep the previous value on failure, but remember why the update failed
+	r.err = err
+	if notModified {
+		// The source confirmed the value, so it is as fresh as a new one, but nothing changed
+		if r.ready {
+			r.updatedAt = now
+		}
+		r.adaptLocked(false)
+		return nil, nil
+	}
+	if err != nil {
+		if action == ClearValue {
+			r.clearLocked()
+		}
+		// Sent under the lock, so Close cannot close the channel in between
+		select {
+		case r.errs <- fmt.Errorf("%s: %w", r, err):
+		default:
+		}
+		return nil, err
+	}
+	oldValue := r.value
+	if r.ready && r.equal != nil && r.equal(oldValue, newValue) {
+		r.adaptLocked(false)
+		return nil, nil
+	}
+	r.adaptLocked(true)
+	r.storeLocked(newValue, now)
+
+	// Nobody to notify, so no closure to allocate
+	if r.onUpdate == nil && r.file == nil && !r.subs.active() && !r.deps.active.Load() {
+		return nil, nil
+	}
+	return func() {
+		if r.onUpdate != nil {
+			r.onUpdate(oldValue, newValue)
+		}
+		r.subs.publish(newValue)
+		r.persist(newValue)
+		if r.deps.active.Load() {
+			r.invalidateDownstreams()
+		}
+	}, nil
+}
+
+// adaptLocked widens the adaptive period after an update finding the value unchanged and shrinks it
+// after a change, see WithAdaptivePeriod, r.mu must be held
+func (r *reCached[T]) adaptLocked(changed bool) {
+	if r.maxPeriod <= 0 {
+		return
+	}
+	if changed {
+		r.adaptive = max(r.adaptive/2, r.minPeriod)
+	} else {
+		r.adaptive = min(2*r.adaptive, r.maxPeriod)
+	}
+}
+
+// handleError returns the action the handler set by WithErrorHandler chooses for a failed update,
+// KeepValue if the update succeeded or there is no handler
+func (r *reCached[T]) handleError(err error) ErrorAction {
+	if err == nil || r.onError == nil || errors.Is(err, errNotModified) {
+		return KeepValue
+	}
+	return r.onError(err)
+}
+
+// clearLocked drops the value after a failed update, see ClearValue, r.mu must be held.
+// Like Reset, the cache is not ready until the next successful update, so the value is not served as fresh
+func (r *reCached[T]) clearLocked() {
+	var zero T
+	r.value = zero
+	r.current.Store(nil)
+	r.bumpVersionLocked()
+	r.updatedAt = time.Time{}
+	if r.ready {
+		r.ready = false
+		r.readyCh = make(chan struct{})
+	}
+}
+
+func (r *reCached[T]) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var zero T
+	r.value = zero
+	r.current.Store(nil)
+	r.err = nil
+	r.version = 0
+	r.updatedAt = time.Time{}
+	if r.ready {
+		r.ready = false
+		r.readyCh = make(chan struct{})
+	}
+	r.lazyLoad = new(sync.Once)
+}
+
+func (r *reCached[T]) Set(value T) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.sets++
+	r.err = nil
+	r.storeLocked(value, r.clock.Now())
+	r.mu.Unlock()
+
+	r.persist(value)
+}
+
+// loadPersisted loads the value stored by WithPersistence, so the cache has a value
+// even if the initial update fails. The cache is not ready until an update succeeds
+func (r *reCached[T]) loadPersisted() {
+	if r.file == nil {
+		return
+	}
+
+	value, info, ok, err := r.file.load()
+	if err != nil {
+		r.log("%s: loading %s failed: %v", r, r.file.path, err)
 		return
 	}
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	r.value = value
+	r.current.Store(&value)
+	r.updatedAt = info.ModTime()
+	r.mu.Unlock()
+}
+
+// persist stores value if WithPersistence is set
+func (r *reCached[T]) persist(value T) {
+	if r.file == nil {
+		return
+	}
+	if err := r.file.save(value); err != nil {
+		r.log("%s: saving %s failed: %v", r, r.file.path, err)
+	}
+}
+
+// log writes a message to the logger, if there is one
+func (r *reCached[T]) log(format string, args ...any) {
+	if r.logger != nil {
+		r.logger.Printf(format, args...)
+	}
+}
+
+// storeLocked replaces the cached value updated at now, r.mu must be held for writing
+func (r *reCached[T]) storeLocked(value T, now time.Time) {
+	r.value = value
+	r.current.Store(&value)
+	r.bumpVersionLocked()
+	if r.seen != nil {
+		r.seen.observe(value)
+	}
+	r.updatedAt = now
+	if !r.ready {
+		r.ready = true
+		close(r.readyCh)
+	}
+}
+
+// callSources calls updateFunc and then the fallbacks in order until one succeeds.
+// It returns the value with the index of the source that returned it, or the joined errors of all sources
+func (r *reCached[T]) callSources(ctx context.Context, updateFunc func(ctx context.Context) (T, error)) (T, int, error) {
+	value, err := r.callSource(ctx, updateFunc)
+	if err == nil || len(r.fallbacks) == 0 || errors.Is(err, errNotModified) {
+		return value, 0, err
+	}
+
+	errs := []error{err}
+	for i, fallback := range r.fallbacks {
+		value, err := r.callSource(ctx, fallback)
+		if err == nil {
+			return value, i + 1, nil
+		}
+		errs = append(errs, err)
+	}
+	var zero T
+	return zero, 0, errors.Join(errs...)
+}
+
+// callSource calls a single source, so a rejected value fails like an error, see WithReject
+func (r *reCached[T]) callSource(ctx context.Context, fn func(ctx context.Context) (T, error)) (T, error) {
+	value, err := call(ctx, fn)
+	if err == nil && r.reject != nil && r.reject(value) {
+		var zero T
+		return zero, ErrRejected
+	}
+	return value, err
+}
+
+// call calls fn, turning a panic into an error
+func call[T any](ctx context.Context, fn func(ctx context.Context) (T, error)) (value T, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%w: %v", ErrPanic, p)
+		}
+	}()
+	return fn(ctx)
+}
+
+// updateContext returns the context for a single call of updateFunc and a function to release it.
+// It is derived from the context passed to the constructor, so its values and deadline propagate.
+// It is also cancelled when caller is done and keeps its deadline, e.g. the one of GlobalCacheUpdateTimeout
+func (r *reCached[T]) updateContext(caller context.Context) (context.Context, context.CancelFunc) {
+	// The context of the cache is cancelled by Close already, a derived one would only cost allocations
+	ctx, cancel := r.ctx, context.CancelFunc(func() {})
+	timeout := r.timeout
+	if r.initial && r.initTime > 0 {
+		timeout = r.initTime
+	}
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	// The caller can never be done, e.g. for automatic updates, so there is nothing to derive
+	if caller.Done() == nil {
+		return ctx, cancel
+	}
+
+	release := cancel
+	if deadline, ok := caller.Deadline(); ok {
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	stop := context.AfterFunc(caller, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		release()
+	}
+}
 
+func (r *reCached[T]) Close() {
 	r.mu.Lock()
-	r.value = newValue
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	close(r.errs)
+	if r.versionCh != nil {
+		close(r.versionCh)
+		r.versionCh = nil
+	}
 	r.mu.Unlock()
+
+	r.cancel()
+	deregister(r)
+	r.subs.close()
+	r.removeDependencies()
+}
+
+func (r *reCached[T]) Errors() <-chan error {
+	return r.errs
+}
+
+func (r *reCached[T]) cacheName() string {
+	return r.name
+}
+
+func (r *reCached[T]) cacheTags() []string {
+	return r.tags
+}
+
+// done reports whether the context of the cache is done, so it is not updated automatically anymore
+func (r *reCached[T]) done() bool {
+	return r.ctx.Err() != nil
+}
+
+func (r *reCached[T]) DebugString() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	age := "-"
+	if !r.updatedAt.IsZero() {
+		age = r.clock.Now().Sub(r.updatedAt).Round(time.Millisecond).String()
+	}
+	return fmt.Sprintf("%s age=%s v=%d ready=%t lastErr=%v", r, age, r.version, r.ready, r.err)
+}
+
+// inScope reports whether the cache was created within s, see Scope
+func (r *reCached[T]) inScope(s *scope) bool {
+	for c := scopeOf(r.ctx); c != nil; c = c.parent {
+		if c == s {
+			return true
+		}
+	}
+	return false
 }
 
-// GlobalCacheUpdate updates all cache instances created via New
-func GlobalCacheUpdate() {
-	globalCachesMutex.RLock()
-	defer globalCachesMutex.RUnlock()
+// String identifies the cache in log messages
+func (r *reCached[T]) String() string {
+	if r.name == "" {
+		return "recached"
+	}
+	return "recached[" + r.name + "]"
+}
 
-	// Create a wait group to update all caches concurrently
-	var wg sync.WaitGroup
-	wg.Add(len(globalCaches))
+func (r *reCached[T]) HealthCheck() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-	// Update all caches concurrently
-	for _, cache := range globalCaches {
-		go func(c interface{ Update() }) {
-			defer wg.Done()
-			c.Update()
-		}(cache)
+	var err error
+	switch {
+	case !r.ready:
+		err = r.wrapErrLocked(ErrNotReady)
+	case r.expiredLocked():
+		err = r.wrapErrLocked(ErrExpired)
+	case r.staleLocked():
+		err = r.wrapErrLocked(ErrStale)
+	case r.breaker != nil && r.breaker.state(r.clock.Now()) == BreakerOpen:
+		err = r.wrapErrLocked(ErrBreakerOpen)
+	default:
+		return nil
 	}
+	return fmt.Errorf("%s: %w", r, err)
+}
 
-	// Wait for all updates to complete
-	wg.Wait()
+// breakerOpen reports whether the circuit breaker keeps the update loop from calling updateFunc
+func (r *reCached[T]) breakerOpen() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.breaker != nil && r.breaker.state(r.clock.Now()) == BreakerOpen
+}
+
+func (r *reCached[T]) isPaused() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.paused
+}
+
+func (r *reCached[T]) isClosed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.closed
 }
@@ -3,6 +3,11 @@ package recached
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -127,45 +132,369 @@ func TestUpdateLoop(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Create a channel to signal when updates occur
-	updateCh := make(chan int, 10)
+	clock := newFakeClock()
 	updateCount := 0
 	updateFunc := func() (int, error) {
 		updateCount++
-		updateCh <- updateCount
 		return updateCount, nil
 	}
 
-	// Create a cache with a short update period
-	cache := New(ctx, 50*time.Millisecond, updateFunc)
+	// Create a cache whose update loop follows the fake clock
+	cache := New(ctx, time.Second, updateFunc, WithClock[int](clock))
+	<-clock.created
+
+	// Every period updates the value exactly once
+	for want := 2; want <= 4; want++ {
+		advanceUpdate(t, clock, cache, time.Second)
+		if got := cache.Get(); got != want {
+			t.Errorf("After update %d, value = %v, want %v", want, got, want)
+		}
+	}
+
+	// Cancel the context to stop the update loop, which stops its ticker on the way out
+	cancel()
+	waitFor(t, func() bool {
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		return clock.tickers[0].stopped
+	}, "the update loop to stop")
 
-	// Wait for at least 3 updates to occur
-	for i := 0; i < 3; i++ {
+	// Verify that the value doesn't change anymore
+	clock.Advance(time.Second)
+	if got := cache.Get(); got != 4 {
+		t.Errorf("After canceling context, value = %v, want %v", got, 4)
+	}
+}
+
+func TestUpdateLoopNoDrift(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	starts := make(chan time.Time, 10)
+	updateFunc := func() (int, error) {
 		select {
-		case <-updateCh:
-			// Update received
-		case <-time.After(500 * time.Millisecond):
+		case starts <- time.Now():
+		default:
+		}
+		// Most of the period is spent in the update
+		time.Sleep(30 * time.Millisecond)
+		return 0, nil
+	}
+
+	cache := New(ctx, 40*time.Millisecond, updateFunc, WithLazy[int]())
+	defer cache.Close()
+
+	var first, last time.Time
+	for i := 0; i < 5; i++ {
+		select {
+		case last = <-starts:
+			if i == 0 {
+				first = last
+			}
+		case <-time.After(time.Second):
 			t.Fatalf("Timed out waiting for update %d", i+1)
 		}
 	}
 
-	// Verify that updateFunc was called multiple times
-	if got := cache.Get(); got < 3 {
-		t.Errorf("After waiting, value = %v, want at least 3", got)
+	// Updates start a period apart, not a period after the previous update finished
+	if avg := last.Sub(first) / 4; avg > 55*time.Millisecond {
+		t.Errorf("Average interval between updates = %v, want about %v", avg, 40*time.Millisecond)
 	}
+}
 
-	// Cancel the context to stop the update loop
-	cancel()
+func TestOverlappingUpdates(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	// Record the current value
-	currentValue := cache.Get()
+	clock := newFakeClock()
+	var slow atomic.Bool
+	updateFunc := func() (int, error) {
+		// The update outlasts three and a half periods
+		if slow.CompareAndSwap(true, false) {
+			clock.Advance(3500 * time.Millisecond)
+		}
+		return 0, nil
+	}
 
-	// Wait to ensure the update loop has stopped
-	time.Sleep(100 * time.Millisecond)
+	cache := New(ctx, time.Second, updateFunc, WithClock[int](clock))
+	defer cache.Close()
+	<-clock.created
 
-	// Verify that the value hasn't changed
-	if got := cache.Get(); got != currentValue {
-		t.Errorf("After canceling context, value = %v, want %v", got, currentValue)
+	// A quick update does not overlap
+	advanceUpdate(t, clock, cache, time.Second)
+	if stats := cache.Stats(); stats.OverlappingUpdates != 0 || stats.SkippedTicks != 0 {
+		t.Errorf("Stats() after a quick update = %+v, want no overlaps", stats)
+	}
+
+	// One of the ticks due during a slow update is kept, the other two are skipped
+	slow.Store(true)
+	clock.Advance(time.Second)
+	deadline := time.Now().Add(time.Second)
+	for cache.Stats().OverlappingUpdates == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the overlapping update")
+		}
+		runtime.Gosched()
+	}
+	if stats := cache.Stats(); stats.OverlappingUpdates != 1 || stats.SkippedTicks != 2 {
+		t.Errorf("Stats() after a slow update = (%v overlapping, %v skipped), want (1, 2)", stats.OverlappingUpdates, stats.SkippedTicks)
+	}
+}
+
+func TestSetPeriod(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	updateFunc := func() (int, error) {
+		return 0, nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc, WithClock[int](clock))
+	defer cache.Close()
+	<-clock.created
+
+	// Non-positive periods are ignored
+	cache.SetPeriod(0)
+	cache.SetPeriod(-time.Second)
+	if got := cache.(*reCached[int]).interval(); got != time.Hour {
+		t.Errorf("interval() after SetPeriod() with non-positive durations = %v, want %v", got, time.Hour)
+	}
+
+	// A shorter period takes effect without waiting for the old one
+	cache.SetPeriod(10 * time.Second)
+	<-clock.resets
+	for i := 0; i < 2; i++ {
+		advanceUpdate(t, clock, cache, 10*time.Second)
+	}
+	if got := cache.Stats().Updates; got != 3 {
+		t.Errorf("Updates after 2 short periods = %v, want %v", got, 3)
+	}
+}
+
+func TestNextRefresh(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	start := clock.Now()
+	cache := New(ctx, 10*time.Second, func() (int, error) {
+		return 1, nil
+	}, WithClock[int](clock))
+	defer cache.Close()
+	<-clock.created
+
+	if got := cache.Period(); got != 10*time.Second {
+		t.Errorf("Period() = %v, want %v", got, 10*time.Second)
+	}
+	waitFor(t, func() bool { return cache.NextRefresh().Equal(start.Add(10 * time.Second)) }, "the first tick to be scheduled")
+
+	// Every tick schedules the next one
+	advanceUpdate(t, clock, cache, 10*time.Second)
+	if got, want := cache.NextRefresh(), start.Add(20*time.Second); !got.Equal(want) {
+		t.Errorf("NextRefresh() after a tick = %v, want %v", got, want)
+	}
+
+	// A new period starts a new interval right away
+	clock.Advance(time.Second)
+	cache.SetPeriod(time.Minute)
+	if got := cache.Period(); got != time.Minute {
+		t.Errorf("Period() after SetPeriod() = %v, want %v", got, time.Minute)
+	}
+	waitFor(t, func() bool { return cache.NextRefresh().Equal(start.Add(71 * time.Second)) }, "the new period to be scheduled")
+
+	// There is no next refresh while paused or after Close
+	cache.Pause()
+	if got := cache.NextRefresh(); !got.IsZero() {
+		t.Errorf("NextRefresh() while paused = %v, want zero", got)
+	}
+	cache.Resume()
+	waitFor(t, func() bool { return cache.NextRefresh().Equal(start.Add(71 * time.Second)) }, "the resumed period to be scheduled")
+	cache.Close()
+	if got := cache.NextRefresh(); !got.IsZero() {
+		t.Errorf("NextRefresh() after Close() = %v, want zero", got)
+	}
+}
+
+func TestNextRefreshWithoutPeriod(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	cache := New(ctx, 0, func() (int, error) {
+		return 1, nil
+	}, WithClock[int](clock))
+	defer cache.Close()
+	<-clock.created
+
+	if got := cache.NextRefresh(); !got.IsZero() {
+		t.Errorf("NextRefresh() without automatic updates = %v, want zero", got)
+	}
+}
+
+func TestNonPositivePeriodDoesNotSpin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, period := range []time.Duration{0, -time.Second} {
+		var calls atomic.Int64
+		cache := New(ctx, period, func() (int64, error) {
+			return calls.Add(1), nil
+		}, WithoutGlobalRegistration[int64]())
+
+		// With the real clock, a spinning loop would call updateFunc many times meanwhile
+		time.Sleep(50 * time.Millisecond)
+		if got := calls.Load(); got != 1 {
+			t.Errorf("updateFunc calls with period %v = %v, want only the initial one", period, got)
+		}
+		cache.Close()
+	}
+}
+
+func TestManualOnly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	var calls atomic.Int64
+	updateFunc := func() (int64, error) {
+		return calls.Add(1), nil
+	}
+
+	cache := New(ctx, 0, updateFunc, WithClock[int64](clock))
+	defer cache.Close()
+	<-clock.created
+
+	// The initial load runs, but time alone never updates the cache
+	clock.Advance(time.Hour)
+	time.Sleep(20 * time.Millisecond)
+	if got := calls.Load(); got != 1 {
+		t.Errorf("updateFunc calls of a manual-only cache = %v, want %v", got, 1)
+	}
+
+	// Invalidate still goes through the loop
+	cache.Invalidate()
+	deadline := time.Now().Add(time.Second)
+	for calls.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the update after Invalidate()")
+		}
+		runtime.Gosched()
+	}
+
+	// A positive period turns automatic updates on
+	cache.SetPeriod(time.Minute)
+	<-clock.resets
+	advanceUpdate(t, clock, cache, time.Minute)
+}
+
+func TestWithTrigger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	var calls atomic.Int64
+	trigger := make(chan struct{})
+	cache := New(ctx, time.Minute, func() (int64, error) {
+		return calls.Add(1), nil
+	}, WithClock[int64](clock), WithTrigger[int64](trigger))
+	defer cache.Close()
+	<-clock.created
+
+	// A trigger updates right away and starts a new interval
+	clock.Advance(30 * time.Second)
+	trigger <- struct{}{}
+	<-clock.resets
+	if got := cache.Get(); got != 2 {
+		t.Errorf("Get() after a trigger = %v, want %v", got, 2)
+	}
+	clock.Advance(40 * time.Second)
+	time.Sleep(20 * time.Millisecond)
+	if got := calls.Load(); got != 2 {
+		t.Errorf("updateFunc calls before the new interval ends = %v, want %v", got, 2)
+	}
+	advanceUpdate(t, clock, cache, 20*time.Second)
+
+	// A closed trigger does not update anymore
+	close(trigger)
+	time.Sleep(20 * time.Millisecond)
+	if got := calls.Load(); got != 3 {
+		t.Errorf("updateFunc calls after closing the trigger = %v, want %v", got, 3)
+	}
+}
+
+func TestSetUpdateFunc(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cache := New(ctx, time.Millisecond, func() (string, error) {
+		return "primary", nil
+	})
+	defer cache.Close()
+
+	// The next update uses the new function while the loop keeps running
+	cache.SetUpdateFunc(func() (string, error) {
+		return "secondary", nil
+	})
+	if err := cache.ForceUpdate(); err != nil {
+		t.Errorf("ForceUpdate() = %v, want nil", err)
+	}
+	if got := cache.Get(); got != "secondary" {
+		t.Errorf("Get() after SetUpdateFunc() = %q, want %q", got, "secondary")
+	}
+
+	// A failing replacement keeps the warm value
+	updateErr := errors.New("update failed")
+	cache.SetUpdateFunc(func() (string, error) {
+		return "", updateErr
+	})
+	if err := cache.ForceUpdate(); !errors.Is(err, updateErr) {
+		t.Errorf("ForceUpdate() = %v, want %v", err, updateErr)
+	}
+	if got := cache.Get(); got != "secondary" {
+		t.Errorf("Get() after a failing replacement = %q, want %q", got, "secondary")
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int64
+	updateFunc := func() (int64, error) {
+		return atomic.AddInt64(&calls, 1), nil
+	}
+
+	cache := New(ctx, 10*time.Millisecond, updateFunc)
+	defer cache.Close()
+
+	// Pausing twice is the same as pausing once
+	cache.Pause()
+	cache.Pause()
+	time.Sleep(20 * time.Millisecond)
+	paused := atomic.LoadInt64(&calls)
+
+	// No automatic updates while paused, but the value is kept and explicit updates work
+	time.Sleep(50 * time.Millisecond)
+	if got := atomic.LoadInt64(&calls); got != paused {
+		t.Errorf("updateFunc calls while paused = %v, want %v", got, paused)
+	}
+	if err := cache.ForceUpdate(); err != nil {
+		t.Errorf("ForceUpdate() while paused = %v, want nil", err)
+	}
+	if got := cache.Get(); got != paused+1 {
+		t.Errorf("Get() after ForceUpdate() while paused = %v, want %v", got, paused+1)
+	}
+
+	// Automatic updates come back after Resume
+	cache.Resume()
+	cache.Resume()
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for atomic.LoadInt64(&calls) < paused+3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for automatic updates after Resume()")
+		}
+		time.Sleep(time.Millisecond)
 	}
 }
 
@@ -213,10 +542,11 @@ func TestConcurrentAccess(t *testing.T) {
 }
 
 func TestGlobalCacheUpdate(t *testing.T) {
-	updateCount := 0
-	updateFunc := func() (int, error) {
-		updateCount++
-		return updateCount, nil
+	isolateRegistry(t)
+
+	var updateCount int64
+	updateFunc := func() (int64, error) {
+		return atomic.AddInt64(&updateCount, 1), nil
 	}
 	ctx := context.Background()
 
@@ -226,7 +556,7 @@ func TestGlobalCacheUpdate(t *testing.T) {
 
 	GlobalCacheUpdate()
 
-	if updateCount != 20 {
+	if updateCount := atomic.LoadInt64(&updateCount); updateCount != 20 {
 		t.Errorf("Expected 20 updates, got %d", updateCount)
 	}
 }
@@ -297,3 +627,2406 @@ func TestCacheLifecycle(t *testing.T) {
 		t.Errorf("After manual update, value = %v, want %v", got, currentValue+1)
 	}
 }
+
+func TestGetWithError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	value := 1
+	var updateErr error
+	updateFunc := func() (int, error) {
+		if updateErr != nil {
+			return 0, updateErr
+		}
+		value++
+		return value, nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc)
+
+	// A successful initial update leaves no error
+	if got, err := cache.GetWithError(); got != 2 || err != nil {
+		t.Errorf("GetWithError() = (%v, %v), want (%v, nil)", got, err, 2)
+	}
+
+	// A failed update keeps the old value and reports the error
+	updateErr = errors.New("update failed")
+	cache.Update()
+	if got, err := cache.GetWithError(); got != 2 || !errors.Is(err, updateErr) {
+		t.Errorf("After failed Update() = (%v, %v), want (%v, %v)", got, err, 2, updateErr)
+	}
+
+	// The error is cleared by the next successful update
+	updateErr = nil
+	cache.Update()
+	if got, err := cache.GetWithError(); got != 3 || err != nil {
+		t.Errorf("After successful Update() = (%v, %v), want (%v, nil)", got, err, 3)
+	}
+}
+
+func TestClose(t *testing.T) {
+	var updateCount int64
+	updateFunc := func() (int64, error) {
+		return atomic.AddInt64(&updateCount, 1), nil
+	}
+
+	cache := New(context.Background(), 10*time.Millisecond, updateFunc)
+
+	// Let the loop run a few times
+	time.Sleep(50 * time.Millisecond)
+
+	cache.Close()
+	lastValue := cache.Get()
+	callsAtClose := atomic.LoadInt64(&updateCount)
+
+	// The cache must be removed from the global registry
+	globalCachesMutex.RLock()
+	_, registered := globalCaches[cache.(registered)]
+	globalCachesMutex.RUnlock()
+	if registered {
+		t.Errorf("Cache is still registered after Close()")
+	}
+
+	// Neither the loop nor explicit updates should call updateFunc anymore
+	time.Sleep(50 * time.Millisecond)
+	cache.Update()
+	if err := cache.ForceUpdate(); !errors.Is(err, ErrClosed) {
+		t.Errorf("ForceUpdate() after Close() = %v, want %v", err, ErrClosed)
+	}
+	if got := atomic.LoadInt64(&updateCount); got != callsAtClose {
+		t.Errorf("updateFunc calls after Close() = %v, want %v", got, callsAtClose)
+	}
+
+	// The last value is still available
+	if got := cache.Get(); got != lastValue {
+		t.Errorf("Get() after Close() = %v, want %v", got, lastValue)
+	}
+
+	// Close is idempotent and safe to call concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Close()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCloseConcurrentGet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The update running during Close is cancelled by it, its failure must not clear the value
+	var armed atomic.Bool
+	running := make(chan struct{})
+	cache := NewCtx(ctx, time.Hour, func(ctx context.Context) (int, error) {
+		if !armed.Load() {
+			return 1, nil
+		}
+		close(running)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}, WithErrorHandler[int](func(error) ErrorAction { return ClearValue }), WithoutGlobalRegistration[int]())
+
+	armed.Store(true)
+	updated := make(chan error, 1)
+	go func() { updated <- cache.ForceUpdate() }()
+	<-running
+
+	// Readers racing Close always get the last value
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if got := cache.Get(); got != 1 {
+					t.Errorf("Get() during Close() = %v, want %v", got, 1)
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(5 * time.Millisecond)
+	cache.Close()
+	if err := <-updated; !errors.Is(err, ErrClosed) {
+		t.Errorf("ForceUpdate() cancelled by Close() = %v, want %v", err, ErrClosed)
+	}
+	time.Sleep(5 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	if got, err := cache.GetWithError(); got != 1 || err != nil {
+		t.Errorf("GetWithError() after Close() = %v, %v, want %v, nil", got, err, 1)
+	}
+	if !cache.Ready() {
+		t.Errorf("Ready() after Close() = false, want true")
+	}
+}
+
+func TestContextCancelDeregisters(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cache := New(ctx, time.Hour, func() (int, error) {
+		return 1, nil
+	}, WithName[int]("test-cancel-deregisters"))
+	defer cache.Close()
+
+	cancel()
+
+	// The update loop removes the cache from the registry once the context is done
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for {
+		globalCachesMutex.RLock()
+		_, registered := globalCaches[cache.(registered)]
+		globalCachesMutex.RUnlock()
+		if !registered {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Cache is still registered after its context was cancelled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := LookupCache("test-cancel-deregisters"); ok {
+		t.Errorf("LookupCache() found a cache with a cancelled context")
+	}
+}
+
+func TestNewOrError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A failing initial update is reported and no cache is created
+	wantErr := errors.New("initial update failed")
+	cache, err := NewOrError(ctx, time.Hour, func() (int, error) {
+		return 0, wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Errorf("NewOrError() error = %v, want %v", err, wantErr)
+	}
+	if cache != nil {
+		t.Errorf("NewOrError() cache = %v, want nil", cache)
+	}
+
+	// A successful initial update returns a working cache
+	cache, err = NewOrError(ctx, time.Hour, func() (int, error) {
+		return 42, nil
+	})
+	if err != nil {
+		t.Fatalf("NewOrError() error = %v, want nil", err)
+	}
+	defer cache.Close()
+	if got := cache.Get(); got != 42 {
+		t.Errorf("Get() = %v, want %v", got, 42)
+	}
+}
+
+func TestWithInitialRetry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	wantErr := errors.New("initial update failed")
+	calls := 0
+	flaky := func() (int, error) {
+		calls++
+		if calls < 3 {
+			return 0, wantErr
+		}
+		return calls, nil
+	}
+
+	// Transient failures are retried
+	cache, err := NewOrError(ctx, time.Hour, flaky, WithInitialRetry[int](3, time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewOrError() error = %v, want nil", err)
+	}
+	defer cache.Close()
+	if got := cache.Get(); got != 3 {
+		t.Errorf("Get() = %v, want %v", got, 3)
+	}
+
+	// It gives up after the last attempt
+	calls = 0
+	_, err = NewOrError(ctx, time.Hour, func() (int, error) {
+		calls++
+		return 0, wantErr
+	}, WithInitialRetry[int](2, time.Millisecond))
+	if !errors.Is(err, wantErr) || calls != 2 {
+		t.Errorf("NewOrError() = %v after %v calls, want %v after %v calls", err, calls, wantErr, 2)
+	}
+}
+
+func TestNewWithFallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	primaryErr := errors.New("primary failed")
+	fallbackErr := errors.New("fallback failed")
+	primaryFails, fallbackFails := false, false
+	primary := func() (int, error) {
+		if primaryFails {
+			return 0, primaryErr
+		}
+		return 1, nil
+	}
+	fallback := func() (int, error) {
+		if fallbackFails {
+			return 0, fallbackErr
+		}
+		return 2, nil
+	}
+
+	cache := NewWithFallback(ctx, time.Hour, primary, fallback)
+	defer cache.Close()
+	if got, source := cache.Get(), cache.Stats().Source; got != 1 || source != 0 {
+		t.Errorf("Get() from primary = %v with Source %v, want %v with Source %v", got, source, 1, 0)
+	}
+
+	// The fallback serves the value while the primary fails
+	primaryFails = true
+	if err := cache.ForceUpdate(); err != nil {
+		t.Errorf("ForceUpdate() with working fallback = %v, want nil", err)
+	}
+	if got, source := cache.Get(), cache.Stats().Source; got != 2 || source != 1 {
+		t.Errorf("Get() from fallback = %v with Source %v, want %v with Source %v", got, source, 2, 1)
+	}
+
+	// Only if all sources fail the update fails
+	fallbackFails = true
+	err := cache.ForceUpdate()
+	if !errors.Is(err, primaryErr) || !errors.Is(err, fallbackErr) {
+		t.Errorf("ForceUpdate() with all sources failing = %v, want %v and %v", err, primaryErr, fallbackErr)
+	}
+	if got := cache.Get(); got != 2 {
+		t.Errorf("Get() after all sources failed = %v, want %v", got, 2)
+	}
+}
+
+func TestNewDelta(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var prevs [][]int
+	updateFunc := func(prev []int) ([]int, error) {
+		prevs = append(prevs, prev)
+		return append(slices.Clone(prev), len(prev)+1), nil
+	}
+
+	cache := NewDelta(ctx, time.Hour, updateFunc)
+	defer cache.Close()
+	cache.Update()
+	cache.Set([]int{10})
+	cache.Update()
+
+	// Each update continues from the cached value, starting with the zero value
+	want := [][]int{nil, {1}, {10}}
+	if !slices.EqualFunc(prevs, want, slices.Equal[[]int]) {
+		t.Errorf("updateFunc received %v, want %v", prevs, want)
+	}
+	if got := cache.Get(); !slices.Equal(got, []int{10, 2}) {
+		t.Errorf("Get() = %v, want %v", got, []int{10, 2})
+	}
+}
+
+func TestNewWithCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	updateFunc := func() (int, error) {
+		called = true
+		return 1, nil
+	}
+
+	// The initial update is skipped
+	cache := New(ctx, time.Hour, updateFunc)
+	defer cache.Close()
+	if called || cache.Ready() {
+		t.Errorf("New() with a cancelled context called updateFunc = %v, Ready() = %v, want false, false", called, cache.Ready())
+	}
+
+	// NewOrError reports why
+	if _, err := NewOrError(ctx, time.Hour, updateFunc); !errors.Is(err, context.Canceled) || called {
+		t.Errorf("NewOrError() with a cancelled context = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestNewConditional(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	modified := true
+	value := 1
+	updateFunc := func() (int, bool, error) {
+		if !modified {
+			return 0, false, nil
+		}
+		return value, true, nil
+	}
+
+	onUpdateCount := 0
+	cache := NewConditional(ctx, time.Hour, updateFunc,
+		WithClock[int](clock),
+		WithOnUpdate(func(old, new int) { onUpdateCount++ }),
+	)
+	defer cache.Close()
+
+	// An unmodified value is kept, but confirmed
+	modified = false
+	clock.Advance(time.Minute)
+	if err := cache.ForceUpdate(); err != nil {
+		t.Errorf("ForceUpdate() without a value = %v, want nil", err)
+	}
+	if got, version := cache.GetVersioned(); got != 1 || version != 1 || onUpdateCount != 1 {
+		t.Errorf("GetVersioned() without a value = (%v, %v) after %v OnUpdate calls, want (%v, %v) after %v", got, version, onUpdateCount, 1, 1, 1)
+	}
+	if got := cache.LastUpdated(); !got.Equal(clock.Now()) {
+		t.Errorf("LastUpdated() without a value = %v, want %v", got, clock.Now())
+	}
+
+	// A modified value is stored
+	modified, value = true, 2
+	cache.Update()
+	if got, version := cache.GetVersioned(); got != 2 || version != 2 || onUpdateCount != 2 {
+		t.Errorf("GetVersioned() with a value = (%v, %v) after %v OnUpdate calls, want (%v, %v) after %v", got, version, onUpdateCount, 2, 2, 2)
+	}
+}
+
+func TestNewCtx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type ctxKey struct{}
+	ctx = context.WithValue(ctx, ctxKey{}, "parent")
+
+	// The update context is derived from the one passed to NewCtx
+	cache := NewCtx(ctx, time.Hour, func(ctx context.Context) (string, error) {
+		v, _ := ctx.Value(ctxKey{}).(string)
+		return v, nil
+	})
+	defer cache.Close()
+
+	if got := cache.Get(); got != "parent" {
+		t.Errorf("Get() = %v, want %v", got, "parent")
+	}
+}
+
+func TestUpdateHooks(t *testing.T) {
+	type key struct{}
+	type spanKey struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "parent"))
+	defer cancel()
+
+	updateErr := errors.New("update failed")
+	fail := false
+	var got []string
+	updateFunc := func(ctx context.Context) (int, error) {
+		got = append(got, fmt.Sprintf("update %v %v", ctx.Value(key{}), ctx.Value(spanKey{})))
+		if fail {
+			return 0, updateErr
+		}
+		return 1, nil
+	}
+
+	cache := NewCtx(ctx, time.Hour, updateFunc,
+		WithOnUpdateStart[int](func(ctx context.Context) context.Context {
+			got = append(got, fmt.Sprintf("start %v", ctx.Value(key{})))
+			return context.WithValue(ctx, spanKey{}, "span")
+		}),
+		WithOnUpdateEnd[int](func(ctx context.Context, err error) {
+			got = append(got, fmt.Sprintf("end %v %v", ctx.Value(spanKey{}), err))
+		}),
+	)
+	defer cache.Close()
+	fail = true
+	cache.Update()
+
+	// Values of the constructor context and of the start hook reach the update function and the end hook
+	want := []string{
+		"start parent", "update parent span", "end span <nil>",
+		"start parent", "update parent span", "end span update failed",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Hook and update calls = %q, want %q", got, want)
+	}
+}
+
+func TestWithSlowThreshold(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	var took atomic.Int64
+	var fail atomic.Bool
+	var slow []time.Duration
+	cache := New(ctx, time.Hour, func() (int, error) {
+		clock.Advance(time.Duration(took.Load()))
+		if fail.Load() {
+			return 0, errors.New("update failed")
+		}
+		return 1, nil
+	}, WithClock[int](clock), WithSlowThreshold[int](time.Second, func(d time.Duration) {
+		slow = append(slow, d)
+	}))
+	defer cache.Close()
+
+	// Quick updates are not reported, slow ones are whether they succeed or not
+	took.Store(int64(time.Second))
+	cache.Update()
+	took.Store(int64(3 * time.Second))
+	cache.Update()
+	fail.Store(true)
+	cache.Update()
+	if want := []time.Duration{3 * time.Second, 3 * time.Second}; !slices.Equal(slow, want) {
+		t.Errorf("Slow updates = %v, want %v", slow, want)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var hang atomic.Bool
+	value := 0
+	updateFunc := func(ctx context.Context) (int, error) {
+		if hang.Load() {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		}
+		value++
+		return value, nil
+	}
+
+	cache := NewCtx(ctx, time.Hour, updateFunc, WithTimeout[int](20*time.Millisecond))
+	defer cache.Close()
+
+	// A hanging update is cut off by the timeout and keeps the old value
+	hang.Store(true)
+	done := make(chan struct{})
+	go func() {
+		cache.Update()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("Timed out waiting for Update() to respect the timeout")
+	}
+
+	if got, err := cache.GetWithError(); got != 1 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("After timed out Update() = (%v, %v), want (%v, %v)", got, err, 1, context.DeadlineExceeded)
+	}
+}
+
+func TestWithInitialTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Every load takes longer than the timeout of the following updates
+	updateFunc := func(ctx context.Context) (int, error) {
+		select {
+		case <-time.After(50 * time.Millisecond):
+			return 1, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+
+	cache := NewCtx(ctx, time.Hour, updateFunc, WithTimeout[int](5*time.Millisecond), WithInitialTimeout[int](time.Minute))
+	defer cache.Close()
+
+	// The initial update gets the longer timeout
+	if got, err := cache.GetWithError(); got != 1 || err != nil {
+		t.Errorf("GetWithError() after the initial update = (%v, %v), want (%v, nil)", got, err, 1)
+	}
+
+	// The following updates do not
+	if err := cache.ForceUpdate(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ForceUpdate() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestWithJitter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	period := 100 * time.Millisecond
+	updateFunc := func() (int, error) {
+		return 0, nil
+	}
+
+	// Without jitter the exact period is used
+	cache := New(ctx, period, updateFunc).(*reCached[int])
+	defer cache.Close()
+	if got := cache.interval(); got != period {
+		t.Errorf("interval() without jitter = %v, want %v", got, period)
+	}
+
+	// With jitter every interval stays within the configured bounds and varies between ticks
+	jittered := New(ctx, period, updateFunc, WithJitter[int](0.2)).(*reCached[int])
+	defer jittered.Close()
+
+	seen := make(map[time.Duration]struct{})
+	for i := 0; i < 100; i++ {
+		got := jittered.interval()
+		if got < 80*time.Millisecond || got > 120*time.Millisecond {
+			t.Fatalf("interval() with jitter = %v, want within [80ms, 120ms]", got)
+		}
+		seen[got] = struct{}{}
+	}
+	if len(seen) < 2 {
+		t.Errorf("interval() with jitter returned the same value every time")
+	}
+
+	// Out of range fractions are clamped
+	for fraction, want := range map[float64]float64{-0.5: 0, 3: 1, math.NaN(): 0} {
+		r := newReCached(ctx, period, ignoreContext(updateFunc), WithJitter[int](fraction))
+		if r.jitter != want {
+			t.Errorf("WithJitter(%v) fraction = %v, want %v", fraction, r.jitter, want)
+		}
+	}
+}
+
+func TestWithRefreshAhead(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	updateCh := make(chan int, 10)
+	value := 0
+	updateFunc := func() (int, error) {
+		value++
+		updateCh <- value
+		return value, nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc,
+		WithClock[int](clock),
+		WithMaxStaleness[int](10*time.Minute),
+		WithRefreshAhead[int](2*time.Minute),
+	)
+	defer cache.Close()
+	<-updateCh
+	<-clock.created
+
+	// The value is refreshed once 8 of its 10 minutes have passed, long before the period
+	for i := 2; i <= 3; i++ {
+		clock.Advance(8 * time.Minute)
+		select {
+		case got := <-updateCh:
+			if got != i {
+				t.Errorf("Update %d returned %v", i, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for update %d", i)
+		}
+		if _, err := cache.GetWithError(); err != nil {
+			t.Errorf("GetWithError() after refresh %d = %v, want nil", i, err)
+		}
+	}
+}
+
+func TestLastUpdated(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fail := false
+	updateFunc := func() (int, error) {
+		if fail {
+			return 0, errors.New("update failed")
+		}
+		return 1, nil
+	}
+
+	before := time.Now()
+	cache := New(ctx, time.Hour, updateFunc)
+	defer cache.Close()
+
+	lastUpdated := cache.LastUpdated()
+	if lastUpdated.Before(before) || lastUpdated.After(time.Now()) {
+		t.Errorf("LastUpdated() = %v, want between %v and now", lastUpdated, before)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if got := cache.Age(); got < 20*time.Millisecond {
+		t.Errorf("Age() = %v, want at least %v", got, 20*time.Millisecond)
+	}
+
+	// A failed update must not move the timestamp forward
+	fail = true
+	cache.Update()
+	if got := cache.LastUpdated(); !got.Equal(lastUpdated) {
+		t.Errorf("LastUpdated() after failed Update() = %v, want %v", got, lastUpdated)
+	}
+
+	// A successful update does
+	fail = false
+	cache.Update()
+	if got := cache.LastUpdated(); !got.After(lastUpdated) {
+		t.Errorf("LastUpdated() after successful Update() = %v, want after %v", got, lastUpdated)
+	}
+}
+
+func TestWithBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The loop is not started, so backoff is driven by hand
+	period := 10 * time.Millisecond
+	cache := newReCached(ctx, period, ignoreContext(func() (int, error) {
+		return 0, nil
+	}), WithBackoff[int](50*time.Millisecond))
+
+	// Consecutive failures grow the interval up to the cap
+	updateErr := errors.New("update failed")
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		cache.backoffAfter(updateErr)
+		if got := cache.interval(); got != w*time.Millisecond {
+			t.Errorf("interval() after %d failures = %v, want %v", i+1, got, w*time.Millisecond)
+		}
+	}
+
+	// The first success resets it to the period
+	cache.backoffAfter(nil)
+	if got := cache.interval(); got != period {
+		t.Errorf("interval() after success = %v, want %v", got, period)
+	}
+}
+
+func TestWithAdaptivePeriod(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	var value atomic.Int64
+	cache := New(ctx, time.Second, func() (int64, error) {
+		return value.Load(), nil
+	}, WithClock[int64](clock), WithEqual(func(a, b int64) bool {
+		return a == b
+	}), WithAdaptivePeriod[int64](time.Second, 4*time.Second))
+	defer cache.Close()
+	<-clock.created
+
+	// An unchanged value widens the period up to max
+	for _, want := range []time.Duration{2 * time.Second, 4 * time.Second} {
+		advanceUpdate(t, clock, cache, cache.Stats().Period)
+		<-clock.resets
+		if got := cache.Stats().Period; got != want {
+			t.Errorf("Stats().Period after an unchanged update = %v, want %v", got, want)
+		}
+	}
+	advanceUpdate(t, clock, cache, 4*time.Second)
+	if got := cache.Stats().Period; got != 4*time.Second {
+		t.Errorf("Stats().Period at max = %v, want %v", got, 4*time.Second)
+	}
+
+	// A change shrinks it again
+	value.Store(1)
+	advanceUpdate(t, clock, cache, 4*time.Second)
+	<-clock.resets
+	if got := cache.Stats().Period; got != 2*time.Second {
+		t.Errorf("Stats().Period after a change = %v, want %v", got, 2*time.Second)
+	}
+	if got := cache.Get(); got != 1 {
+		t.Errorf("Get() after a change = %v, want %v", got, 1)
+	}
+}
+
+func TestSet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updateCount := 0
+	updateErr := errors.New("update failed")
+	cache := New(ctx, time.Hour, func() (string, error) {
+		updateCount++
+		return "", updateErr
+	})
+	defer cache.Close()
+
+	lastUpdated := cache.LastUpdated()
+	cache.Set("pushed")
+
+	// Set replaces the value and clears the error without calling updateFunc
+	if got, err := cache.GetWithError(); got != "pushed" || err != nil {
+		t.Errorf("GetWithError() after Set() = (%v, %v), want (%v, nil)", got, err, "pushed")
+	}
+	if updateCount != 1 {
+		t.Errorf("updateCount = %v, want %v", updateCount, 1)
+	}
+	if got := cache.LastUpdated(); !got.After(lastUpdated) {
+		t.Errorf("LastUpdated() after Set() = %v, want after %v", got, lastUpdated)
+	}
+
+}
+
+func TestReset(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+	updateFunc := func() (int, error) {
+		calls++
+		return calls, nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc)
+	defer cache.Close()
+	lazy := New(ctx, time.Hour, updateFunc, WithLazy[int]())
+	defer lazy.Close()
+	lazy.Get()
+
+	// The value is gone until the next update
+	cache.Reset()
+	if got, err := cache.GetWithError(); got != 0 || !errors.Is(err, ErrNotReady) {
+		t.Errorf("GetWithError() after Reset() = (%v, %v), want (0, %v)", got, err, ErrNotReady)
+	}
+	if cache.Ready() || cache.Version() != 0 || !cache.LastUpdated().IsZero() {
+		t.Errorf("Reset() kept Ready() = %v, Version() = %v, LastUpdated() = %v", cache.Ready(), cache.Version(), cache.LastUpdated())
+	}
+	cache.Update()
+	if got := cache.Get(); got != 3 || !cache.Ready() {
+		t.Errorf("Get() after Reset() and Update() = %v, want %v", got, 3)
+	}
+
+	// A lazy cache loads it again on the next read
+	lazy.Reset()
+	if got := lazy.Get(); got != 4 {
+		t.Errorf("Get() of a lazy cache after Reset() = %v, want %v", got, 4)
+	}
+}
+
+func TestSetDuringUpdate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	slow := false
+	cache := New(ctx, time.Hour, func() (string, error) {
+		if slow {
+			started <- struct{}{}
+			<-release
+		}
+		return "fetched", nil
+	})
+	defer cache.Close()
+
+	slow = true
+	done := make(chan struct{})
+	go func() {
+		cache.Update()
+		close(done)
+	}()
+
+	// A value set while the update is running wins over its older result
+	<-started
+	cache.Set("pushed")
+	close(release)
+	<-done
+
+	if got := cache.Get(); got != "pushed" {
+		t.Errorf("Get() after Set() during Update() = %q, want %q", got, "pushed")
+	}
+}
+
+func TestWithRejectZero(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	value := "full"
+	updateFunc := func() (string, error) {
+		return value, nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc, WithRejectZero[string]())
+	defer cache.Close()
+
+	// An empty result keeps the previous value and is reported
+	value = ""
+	if err := cache.ForceUpdate(); !errors.Is(err, ErrRejected) {
+		t.Errorf("ForceUpdate() returning the zero value = %v, want %v", err, ErrRejected)
+	}
+	if got, err := cache.GetWithError(); got != "full" || !errors.Is(err, ErrRejected) {
+		t.Errorf("GetWithError() after rejected update = (%q, %v), want (%q, %v)", got, err, "full", ErrRejected)
+	}
+
+	// Without the option it is accepted
+	plain := New(ctx, time.Hour, updateFunc)
+	defer plain.Close()
+	if got, err := plain.GetWithError(); got != "" || err != nil {
+		t.Errorf("GetWithError() without WithRejectZero = (%q, %v), want (%q, nil)", got, err, "")
+	}
+}
+
+func TestWithErrorHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updateErr := errors.New("update failed")
+	var calls, failures int
+	updateFunc := func() (int, error) {
+		calls++
+		if failures > 0 {
+			failures--
+			return 0, updateErr
+		}
+		return calls, nil
+	}
+
+	// ClearValue drops the value but keeps reporting the error
+	cleared := New(ctx, time.Hour, updateFunc, WithErrorHandler[int](func(err error) ErrorAction {
+		return ClearValue
+	}), WithoutGlobalRegistration[int]())
+	defer cleared.Close()
+	failures = 1
+	if err := cleared.ForceUpdate(); !errors.Is(err, updateErr) {
+		t.Errorf("ForceUpdate() = %v, want %v", err, updateErr)
+	}
+	if got := cleared.Get(); got != 0 {
+		t.Errorf("Get() after a cleared update = %v, want %v", got, 0)
+	}
+	if got, err := cleared.GetWithError(); got != 0 || !errors.Is(err, updateErr) {
+		t.Errorf("GetWithError() after a cleared update = (%v, %v), want (0, %v)", got, err, updateErr)
+	}
+	if !cleared.LastUpdated().IsZero() {
+		t.Errorf("LastUpdated() after a cleared update = %v, want the zero time", cleared.LastUpdated())
+	}
+
+	// A cleared cache is not ready, so the zero value is never served as fresh
+	if got, ok := cleared.GetOK(); got != 0 || ok {
+		t.Errorf("GetOK() after a cleared update = (%v, %v), want (0, false)", got, ok)
+	}
+	if err := cleared.HealthCheck(); !errors.Is(err, ErrNotReady) || !errors.Is(err, updateErr) {
+		t.Errorf("HealthCheck() after a cleared update = %v, want %v with %v", err, ErrNotReady, updateErr)
+	}
+	if cleared.Ready() {
+		t.Errorf("Ready() after a cleared update = true, want false")
+	}
+
+	// The next successful update makes it ready again
+	if err := cleared.ForceUpdate(); err != nil {
+		t.Errorf("ForceUpdate() after a cleared update = %v, want nil", err)
+	}
+	if got, ok := cleared.GetOK(); got != 3 || !ok {
+		t.Errorf("GetOK() after the next update = (%v, %v), want (3, true)", got, ok)
+	}
+	if err := cleared.WaitReady(ctx); err != nil {
+		t.Errorf("WaitReady() after the next update = %v, want nil", err)
+	}
+
+	// Retry calls the update function once more
+	calls = 0
+	retried := New(ctx, time.Hour, updateFunc, WithErrorHandler[int](func(err error) ErrorAction {
+		return Retry
+	}), WithoutGlobalRegistration[int]())
+	defer retried.Close()
+	failures = 1
+	if err := retried.ForceUpdate(); err != nil {
+		t.Errorf("ForceUpdate() with a successful retry = %v, want nil", err)
+	}
+	if got := retried.Get(); got != 3 {
+		t.Errorf("Get() after a successful retry = %v, want %v", got, 3)
+	}
+
+	// but not twice
+	failures = 5
+	before := calls
+	if err := retried.ForceUpdate(); !errors.Is(err, updateErr) {
+		t.Errorf("ForceUpdate() with a failed retry = %v, want %v", err, updateErr)
+	}
+	if got := calls - before; got != 2 {
+		t.Errorf("updateFunc calls with a failed retry = %v, want %v", got, 2)
+	}
+	if got := retried.Get(); got != 3 {
+		t.Errorf("Get() after a failed retry = %v, want the kept value %v", got, 3)
+	}
+	if got := retried.Stats().Failures; got != 1 {
+		t.Errorf("Stats().Failures = %v, want %v", got, 1)
+	}
+}
+
+func TestWithOnUpdate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	value := 0
+	fail := false
+	updateFunc := func() (int, error) {
+		if fail {
+			return 0, errors.New("update failed")
+		}
+		value++
+		return value, nil
+	}
+
+	type change struct{ old, new int }
+	var changes []change
+	var cache ReCached[int]
+	onUpdate := func(old, new int) {
+		changes = append(changes, change{old, new})
+		// The callback may use the cache without deadlocking
+		if cache != nil {
+			_ = cache.Get()
+		}
+	}
+
+	cache = New(ctx, time.Hour, updateFunc, WithOnUpdate(onUpdate))
+	defer cache.Close()
+
+	cache.Update()
+
+	// A failed update does not fire the callback
+	fail = true
+	cache.Update()
+
+	want := []change{{0, 1}, {1, 2}}
+	if len(changes) != len(want) {
+		t.Fatalf("OnUpdate calls = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("OnUpdate call %d = %v, want %v", i, changes[i], want[i])
+		}
+	}
+}
+
+func TestWithEqual(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	value := []int{1, 2, 3}
+	updateFunc := func() ([]int, error) {
+		return append([]int(nil), value...), nil
+	}
+	equal := func(a, b []int) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	onUpdateCount := 0
+	cache := New(ctx, time.Hour, updateFunc,
+		WithEqual(equal),
+		WithOnUpdate(func(old, new []int) { onUpdateCount++ }),
+	)
+	defer cache.Close()
+
+	lastUpdated := cache.LastUpdated()
+
+	// An unchanged value is neither stored nor notified
+	cache.Update()
+	if onUpdateCount != 1 {
+		t.Errorf("OnUpdate calls after unchanged Update() = %v, want %v", onUpdateCount, 1)
+	}
+	if got := cache.LastUpdated(); !got.Equal(lastUpdated) {
+		t.Errorf("LastUpdated() after unchanged Update() = %v, want %v", got, lastUpdated)
+	}
+
+	// A changed value is
+	value = []int{4, 5}
+	cache.Update()
+	if onUpdateCount != 2 {
+		t.Errorf("OnUpdate calls after changed Update() = %v, want %v", onUpdateCount, 2)
+	}
+	if got := cache.Get(); !equal(got, value) {
+		t.Errorf("Get() after changed Update() = %v, want %v", got, value)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updateErr := errors.New("update failed")
+	value, fail := 1, false
+	updateFunc := func() (int, error) {
+		if fail {
+			return 0, updateErr
+		}
+		return value, nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc, WithEqual(func(a, b int) bool { return a == b }))
+	defer cache.Close()
+
+	if got, version := cache.GetVersioned(); got != 1 || version != 1 {
+		t.Errorf("GetVersioned() after initial update = (%v, %v), want (%v, %v)", got, version, 1, 1)
+	}
+
+	// Neither an unchanged value nor a failure is a new version
+	cache.Update()
+	fail = true
+	cache.Update()
+	if got := cache.Version(); got != 1 {
+		t.Errorf("Version() after unchanged and failed updates = %v, want %v", got, 1)
+	}
+
+	// A changed value and Set are
+	fail, value = false, 2
+	cache.Update()
+	cache.Set(3)
+	if got, version := cache.GetVersioned(); got != 3 || version != 3 {
+		t.Errorf("GetVersioned() after changed update and Set() = (%v, %v), want (%v, %v)", got, version, 3, 3)
+	}
+}
+
+func TestWaitForVersion(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cache := New(ctx, time.Hour, func() (int, error) {
+		return 1, nil
+	})
+	defer cache.Close()
+
+	// A reached version returns right away
+	if got, err := cache.WaitForVersion(ctx, 1); got != 1 || err != nil {
+		t.Errorf("WaitForVersion() of the current version = (%v, %v), want (%v, nil)", got, err, 1)
+	}
+
+	// A later version is waited for
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if got, err := cache.WaitForVersion(ctx, 3); got != 3 || err != nil {
+			t.Errorf("WaitForVersion() of a later version = (%v, %v), want (%v, nil)", got, err, 3)
+		}
+	}()
+	cache.Set(2)
+	select {
+	case <-done:
+		t.Fatalf("WaitForVersion() returned before the version was reached")
+	case <-time.After(20 * time.Millisecond):
+	}
+	cache.Set(3)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("WaitForVersion() did not return after the version was reached")
+	}
+
+	// A done context returns the current value with the context error
+	timeoutCtx, timeoutCancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer timeoutCancel()
+	if got, err := cache.WaitForVersion(timeoutCtx, 10); got != 3 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitForVersion() past the deadline = (%v, %v), want (%v, %v)", got, err, 3, context.DeadlineExceeded)
+	}
+
+	// Closing the cache wakes the waiters
+	closed := make(chan error, 1)
+	go func() {
+		_, err := cache.WaitForVersion(ctx, 10)
+		closed <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cache.Close()
+	select {
+	case err := <-closed:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("WaitForVersion() on a closed cache = %v, want %v", err, ErrClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("WaitForVersion() did not return after Close()")
+	}
+}
+
+func TestGetWithMeta(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	updateErr := errors.New("update failed")
+	fail := false
+	updateFunc := func() (int, error) {
+		if fail {
+			return 0, updateErr
+		}
+		return 1, nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc, WithClock[int](clock), WithMaxStaleness[int](time.Minute))
+	defer cache.Close()
+
+	got, meta := cache.GetWithMeta()
+	want := Meta{LastUpdated: clock.Now(), Version: 1}
+	if got != 1 || meta != want {
+		t.Errorf("GetWithMeta() = (%v, %+v), want (%v, %+v)", got, meta, 1, want)
+	}
+
+	fail = true
+	cache.Update()
+	clock.Advance(2 * time.Minute)
+	got, meta = cache.GetWithMeta()
+	want = Meta{LastUpdated: want.LastUpdated, Version: 1, LastError: updateErr, Stale: true}
+	if got != 1 || meta != want {
+		t.Errorf("GetWithMeta() when stale = (%v, %+v), want (%v, %+v)", got, meta, 1, want)
+	}
+}
+
+func TestDebugString(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	updateErr := errors.New("update failed")
+	var fail atomic.Bool
+	fail.Store(true)
+	cache := New(ctx, time.Hour, func() (int, error) {
+		if fail.Load() {
+			return 0, updateErr
+		}
+		return 1, nil
+	}, WithClock[int](clock), WithName[int]("debug"), WithoutGlobalRegistration[int]())
+	defer cache.Close()
+
+	if got, want := cache.DebugString(), "recached[debug] age=- v=0 ready=false lastErr=update failed"; got != want {
+		t.Errorf("DebugString() without a value = %q, want %q", got, want)
+	}
+
+	fail.Store(false)
+	cache.Update()
+	clock.Advance(12 * time.Second)
+	if got, want := cache.DebugString(), "recached[debug] age=12s v=1 ready=true lastErr=<nil>"; got != want {
+		t.Errorf("DebugString() = %q, want %q", got, want)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	updateErr := errors.New("update failed")
+	fail := true
+	updateFunc := func() (int, error) {
+		if fail {
+			return 0, updateErr
+		}
+		return 1, nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc,
+		WithClock[int](clock),
+		WithName[int]("health"),
+		WithoutGlobalRegistration[int](),
+		WithTTL[int](time.Hour),
+		WithMaxStaleness[int](time.Minute),
+		WithCircuitBreaker[int](2, time.Minute),
+	)
+	defer cache.Close()
+
+	if err := cache.HealthCheck(); !errors.Is(err, ErrNotReady) || !errors.Is(err, updateErr) {
+		t.Errorf("HealthCheck() before the first load = %v, want %v", err, ErrNotReady)
+	}
+
+	fail = false
+	cache.Update()
+	if err := cache.HealthCheck(); err != nil {
+		t.Errorf("HealthCheck() of a fresh value = %v, want nil", err)
+	}
+
+	// Two failures open the breaker
+	fail = true
+	cache.Update()
+	cache.Update()
+	err := cache.HealthCheck()
+	if !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("HealthCheck() with open breaker = %v, want %v", err, ErrBreakerOpen)
+	}
+	if err == nil || !strings.HasPrefix(err.Error(), "recached[health]: ") {
+		t.Errorf("HealthCheck() = %v, want it to name the cache", err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if err := cache.HealthCheck(); !errors.Is(err, ErrStale) {
+		t.Errorf("HealthCheck() of a stale value = %v, want %v", err, ErrStale)
+	}
+	clock.Advance(time.Hour)
+	if err := cache.HealthCheck(); !errors.Is(err, ErrExpired) {
+		t.Errorf("HealthCheck() of an expired value = %v, want %v", err, ErrExpired)
+	}
+}
+
+func TestUpdateCoalescing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int64
+	release := make(chan struct{})
+	updateFunc := func() (int64, error) {
+		n := atomic.AddInt64(&calls, 1)
+		// Every update after the initial one blocks until released
+		if n > 1 {
+			<-release
+		}
+		return n, nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc)
+	defer cache.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Update()
+		}()
+	}
+
+	// Give all callers time to join the in-flight update
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt64(&calls); got != 2 {
+		t.Errorf("updateFunc calls = %v, want %v", got, 2)
+	}
+	if got := cache.Get(); got != 2 {
+		t.Errorf("Get() = %v, want %v", got, 2)
+	}
+}
+
+func TestGetFresh(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	updateErr := errors.New("update failed")
+	var calls int64
+	var fail atomic.Bool
+	release := make(chan struct{})
+	updateFunc := func() (int64, error) {
+		n := atomic.AddInt64(&calls, 1)
+		if n == 2 {
+			<-release
+		}
+		if fail.Load() {
+			return 0, updateErr
+		}
+		return n, nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc, WithClock[int64](clock))
+	defer cache.Close()
+
+	// A young enough value is returned without updating
+	clock.Advance(time.Minute)
+	if got, err := cache.GetFresh(time.Minute); got != 1 || err != nil {
+		t.Errorf("GetFresh() of a fresh value = (%v, %v), want (%v, nil)", got, err, 1)
+	}
+
+	// An old value is updated once for all concurrent callers
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := cache.GetFresh(time.Second); got != 2 || err != nil {
+				t.Errorf("GetFresh() of an old value = (%v, %v), want (%v, nil)", got, err, 2)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if got := atomic.LoadInt64(&calls); got != 2 {
+		t.Errorf("updateFunc calls = %v, want %v", got, 2)
+	}
+
+	// A failed update returns the old value with the error
+	fail.Store(true)
+	clock.Advance(time.Minute)
+	if got, err := cache.GetFresh(time.Second); got != 2 || !errors.Is(err, updateErr) {
+		t.Errorf("GetFresh() with a failing update = (%v, %v), want (%v, %v)", got, err, 2, updateErr)
+	}
+}
+
+func TestGetSWR(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	var calls atomic.Int64
+	release := make(chan struct{})
+	updateFunc := func() (int64, error) {
+		n := calls.Add(1)
+		if n == 2 {
+			<-release
+		}
+		return n, nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc, WithClock[int64](clock))
+	defer cache.Close()
+
+	// A young enough value is returned without updating
+	clock.Advance(time.Minute)
+	if got := cache.GetSWR(time.Minute); got != 1 {
+		t.Errorf("GetSWR() of a fresh value = %v, want %v", got, 1)
+	}
+
+	// An old value is returned right away while a single update runs in the background
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := cache.GetSWR(time.Second); got != 1 {
+				t.Errorf("GetSWR() of an old value = %v, want %v", got, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for cache.Get() != 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := cache.Get(); got != 2 {
+		t.Errorf("Get() after the background update = %v, want %v", got, 2)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("updateFunc calls = %v, want %v", got, 2)
+	}
+}
+
+func TestReadsDoNotWaitForUpdates(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int64
+	started := make(chan struct{})
+	release := make(chan struct{})
+	updateFunc := func() (int64, error) {
+		if atomic.AddInt64(&calls, 1) > 1 {
+			close(started)
+			<-release
+		}
+		return atomic.LoadInt64(&calls), nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc)
+	defer cache.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.Update()
+	}()
+	<-started
+
+	// Every read returns the previous value right away while the update is stuck
+	reads := map[string]func() int64{
+		"Get": cache.Get,
+		"GetWithError": func() int64 {
+			v, _ := cache.GetWithError()
+			return v
+		},
+		"GetFresh": func() int64 {
+			v, _ := cache.GetFresh(time.Hour)
+			return v
+		},
+		"GetWithMeta": func() int64 {
+			v, _ := cache.GetWithMeta()
+			return v
+		},
+		"Stats": func() int64 {
+			return int64(cache.Stats().Updates)
+		},
+		"HealthCheck": func() int64 {
+			if err := cache.HealthCheck(); err != nil {
+				return 0
+			}
+			return 1
+		},
+	}
+	for name, read := range reads {
+		start := time.Now()
+		got := read()
+		if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+			t.Errorf("%s() took %v during a slow update", name, elapsed)
+		}
+		if got != 1 {
+			t.Errorf("%s() during a slow update = %v, want %v", name, got, 1)
+		}
+	}
+
+	close(release)
+	<-done
+	if got := cache.Get(); got != 2 {
+		t.Errorf("Get() after the update = %v, want %v", got, 2)
+	}
+}
+
+func TestWithTryLock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	var calls atomic.Int64
+	cache := New(ctx, time.Second, func() (int64, error) {
+		return calls.Add(1), nil
+	}, WithClock[int64](clock), WithTryLock[int64]())
+	defer cache.Close()
+	<-clock.created
+
+	// A tick while a reader holds the lock drops its result
+	inner := cache.(*reCached[int64])
+	inner.mu.RLock()
+	clock.Advance(time.Second)
+	deadline := time.Now().Add(time.Second)
+	for cache.Stats().SkippedTicks == 0 {
+		if time.Now().After(deadline) {
+			inner.mu.RUnlock()
+			t.Fatalf("Timed out waiting for the skipped tick")
+		}
+		runtime.Gosched()
+	}
+	inner.mu.RUnlock()
+	if got := cache.Get(); got != 1 {
+		t.Errorf("Get() after a skipped tick = %v, want %v", got, 1)
+	}
+
+	// The next tick stores its result
+	advanceUpdate(t, clock, cache, time.Second)
+	if got := cache.Get(); got != 3 {
+		t.Errorf("Get() after the next tick = %v, want %v", got, 3)
+	}
+	if got := cache.Stats().SkippedTicks; got != 1 {
+		t.Errorf("Stats().SkippedTicks = %v, want %v", got, 1)
+	}
+}
+
+func TestWithTryLockExplicitJoin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	var calls atomic.Int64
+	var block atomic.Bool
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	cache := New(ctx, time.Second, func() (int64, error) {
+		if block.CompareAndSwap(true, false) {
+			started <- struct{}{}
+			<-release
+		}
+		return calls.Add(1), nil
+	}, WithClock[int64](clock), WithTryLock[int64]())
+	defer cache.Close()
+	<-clock.created
+
+	// A tick runs while a reader holds the lock, an explicit update joins it
+	inner := cache.(*reCached[int64])
+	inner.mu.RLock()
+	block.Store(true)
+	clock.Advance(time.Second)
+	<-started
+	updated := make(chan error, 1)
+	go func() { updated <- cache.ForceUpdate() }()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	// The tick drops its value, but the explicit update waits for the lock and stores one
+	select {
+	case err := <-updated:
+		inner.mu.RUnlock()
+		t.Fatalf("ForceUpdate() = %v while a reader holds the lock, want it to wait", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	inner.mu.RUnlock()
+	if err := <-updated; err != nil {
+		t.Errorf("ForceUpdate() = %v, want nil", err)
+	}
+	if got := cache.Get(); got != calls.Load() || got < 2 {
+		t.Errorf("Get() after ForceUpdate() = %v, want the last of %v calls", got, calls.Load())
+	}
+}
+
+func TestWithTryLockFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	updateErr := errors.New("update failed")
+	var fail atomic.Bool
+	cache := New(ctx, time.Second, func() (int, error) {
+		if fail.Load() {
+			return 0, updateErr
+		}
+		return 1, nil
+	}, WithClock[int](clock), WithTryLock[int]())
+	defer cache.Close()
+	<-clock.created
+
+	// A failed tick waits for the reader and records the failure instead of dropping it
+	inner := cache.(*reCached[int])
+	inner.mu.RLock()
+	fail.Store(true)
+	clock.Advance(time.Second)
+	time.Sleep(10 * time.Millisecond)
+	inner.mu.RUnlock()
+
+	waitFor(t, func() bool { return cache.Stats().Failures == 1 }, "the failure to be recorded")
+	if got, err := cache.GetWithError(); got != 1 || !errors.Is(err, updateErr) {
+		t.Errorf("GetWithError() after a failed tick = %v, %v, want %v, %v", got, err, 1, updateErr)
+	}
+	select {
+	case err := <-cache.Errors():
+		if !errors.Is(err, updateErr) {
+			t.Errorf("Errors() received %v, want %v", err, updateErr)
+		}
+	default:
+		t.Error("Errors() received nothing after a failed tick")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updateErr := errors.New("update failed")
+	fail := false
+	updateFunc := func() (int, error) {
+		if fail {
+			return 0, updateErr
+		}
+		return 1, nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc, WithName[int]("errors"), WithoutGlobalRegistration[int]())
+	errs := cache.Errors()
+
+	// Only failures are delivered
+	cache.Update()
+	fail = true
+	cache.Update()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, updateErr) || !strings.HasPrefix(err.Error(), "recached[errors]: ") {
+			t.Errorf("Errors() received %v, want %v of the named cache", err, updateErr)
+		}
+	default:
+		t.Fatalf("Errors() received nothing after a failed update")
+	}
+
+	// A full channel drops errors instead of blocking updates
+	for i := 0; i < errorBuffer+5; i++ {
+		cache.Update()
+	}
+	if got := len(errs); got != errorBuffer {
+		t.Errorf("Errors() buffered %v errors, want %v", got, errorBuffer)
+	}
+
+	cache.Close()
+	for range errs {
+	}
+}
+
+func TestForceUpdate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	value := 1
+	var updateErr error
+	cache := New(ctx, time.Hour, func() (int, error) {
+		if updateErr != nil {
+			return 0, updateErr
+		}
+		value++
+		return value, nil
+	})
+	defer cache.Close()
+
+	if err := cache.ForceUpdate(); err != nil {
+		t.Errorf("ForceUpdate() = %v, want nil", err)
+	}
+	if got := cache.Get(); got != 3 {
+		t.Errorf("After ForceUpdate() = %v, want %v", got, 3)
+	}
+
+	// A failure is returned and the value is kept
+	updateErr = errors.New("update failed")
+	if err := cache.ForceUpdate(); !errors.Is(err, updateErr) {
+		t.Errorf("ForceUpdate() = %v, want %v", err, updateErr)
+	}
+	if got := cache.Get(); got != 3 {
+		t.Errorf("After failed ForceUpdate() = %v, want %v", got, 3)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	updateCh := make(chan int, 10)
+	value := 0
+	updateFunc := func() (int, error) {
+		value++
+		updateCh <- value
+		return value, nil
+	}
+
+	cache := New(ctx, time.Minute, updateFunc, WithClock[int](clock))
+	defer cache.Close()
+	<-updateCh
+	<-clock.created
+
+	// The loop updates without the clock moving
+	clock.Advance(30 * time.Second)
+	cache.Invalidate()
+	select {
+	case got := <-updateCh:
+		if got != 2 {
+			t.Errorf("Update after Invalidate() returned %v, want %v", got, 2)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for the update after Invalidate()")
+	}
+
+	// The next automatic update is a full period later
+	<-clock.resets
+	clock.Advance(30 * time.Second)
+	advanceUpdate(t, clock, cache, 30*time.Second)
+	if got := <-updateCh; got != 3 {
+		t.Errorf("Automatic update after Invalidate() returned %v, want %v", got, 3)
+	}
+	select {
+	case got := <-updateCh:
+		t.Errorf("Unexpected update %v before a full period passed", got)
+	default:
+	}
+}
+
+func TestWithCopy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updateFunc := func() ([]int, error) {
+		return []int{1, 2, 3}, nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc, WithCopy(slices.Clone[[]int]))
+	defer cache.Close()
+
+	// Modifying a returned value does not change the cached one
+	cache.Get()[0] = 100
+	if got, _ := cache.GetWithError(); got[0] != 1 {
+		t.Errorf("GetWithError()[0] after modifying Get() = %v, want %v", got[0], 1)
+	}
+	got, _ := cache.GetFresh(time.Hour)
+	got[1] = 200
+	if got := cache.Get(); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("Get() after modifying GetFresh() = %v, want %v", got, []int{1, 2, 3})
+	}
+}
+
+func TestWithMinInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	calls := 0
+	updateFunc := func() (int, error) {
+		calls++
+		return calls, nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc, WithClock[int](clock), WithMinInterval[int](time.Minute))
+	defer cache.Close()
+
+	// Explicit updates right after the initial one are dropped and counted
+	if err := cache.ForceUpdate(); !errors.Is(err, ErrThrottled) {
+		t.Errorf("ForceUpdate() within the minimum interval = %v, want %v", err, ErrThrottled)
+	}
+	cache.Update()
+	if got, stats := cache.Get(), cache.Stats(); got != 1 || stats.Throttled != 2 {
+		t.Errorf("Get() = %v with %v throttled updates, want %v with %v", got, stats.Throttled, 1, 2)
+	}
+
+	// Once the interval has passed they go through
+	clock.Advance(time.Minute)
+	if err := cache.ForceUpdate(); err != nil {
+		t.Errorf("ForceUpdate() after the minimum interval = %v, want nil", err)
+	}
+	if got := cache.Get(); got != 2 {
+		t.Errorf("Get() after the minimum interval = %v, want %v", got, 2)
+	}
+}
+
+func TestWithLazy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int64
+	updateFunc := func() (int64, error) {
+		return atomic.AddInt64(&calls, 1), nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc, WithLazy[int64]())
+	defer cache.Close()
+
+	// Construction does not load the value
+	if got := atomic.LoadInt64(&calls); got != 0 {
+		t.Errorf("updateFunc calls after New() = %v, want %v", got, 0)
+	}
+
+	// The first Get loads it, later ones use the cached value
+	if got := cache.Get(); got != 1 {
+		t.Errorf("First Get() = %v, want %v", got, 1)
+	}
+	if got := cache.Get(); got != 1 {
+		t.Errorf("Second Get() = %v, want %v", got, 1)
+	}
+	if got := atomic.LoadInt64(&calls); got != 1 {
+		t.Errorf("updateFunc calls after Get() = %v, want %v", got, 1)
+	}
+}
+
+func TestWithLazyFailedLoad(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int64
+	updateFunc := func() (int, error) {
+		atomic.AddInt64(&calls, 1)
+		return 0, errors.New("update failed")
+	}
+
+	cache := New(ctx, time.Hour, updateFunc, WithLazy[int]())
+	defer cache.Close()
+
+	// Only the first read tries to load, the rest is left to the update loop
+	for i := 0; i < 10; i++ {
+		_ = cache.Get()
+	}
+	if got := atomic.LoadInt64(&calls); got != 1 {
+		t.Errorf("updateFunc calls = %v, want %v", got, 1)
+	}
+}
+
+func TestWithLazyNoWait(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	release := make(chan struct{})
+	updateFunc := func() (string, error) {
+		<-release
+		return "loaded", nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc, WithLazyNoWait[string]())
+	defer cache.Close()
+
+	// Get does not wait for the load
+	if got := cache.Get(); got != "" {
+		t.Errorf("Get() before load = %q, want zero value", got)
+	}
+
+	close(release)
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for cache.Get() != "loaded" {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the background load")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWithImmediateTick(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	var calls atomic.Int64
+	updateFunc := func() (int64, error) {
+		return calls.Add(1), nil
+	}
+
+	// A lazy cache is loaded by the loop without waiting for the period
+	lazy := New(ctx, time.Hour, updateFunc, WithClock[int64](clock), WithLazyNoWait[int64](), WithImmediateTick[int64]())
+	defer lazy.Close()
+	if err := lazy.WaitReady(ctx); err != nil {
+		t.Fatalf("WaitReady() = %v", err)
+	}
+	if got := lazy.Get(); got != 1 {
+		t.Errorf("Get() after the immediate tick = %v, want %v", got, 1)
+	}
+
+	// A value loaded by the constructor is not loaded again
+	calls.Store(0)
+	eager := New(ctx, time.Hour, updateFunc, WithClock[int64](clock), WithImmediateTick[int64]())
+	defer eager.Close()
+	<-clock.created
+	<-clock.created
+	time.Sleep(20 * time.Millisecond)
+	if got := calls.Load(); got != 1 {
+		t.Errorf("updateFunc calls with an initial update = %v, want %v", got, 1)
+	}
+}
+
+func TestWithReadThrough(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	release := make(chan struct{})
+	var reads atomic.Int64
+	cache := New(ctx, time.Hour, func() (string, error) {
+		<-release
+		return "primary", nil
+	}, WithLazyNoWait[string](), WithReadThrough(func() (string, bool) {
+		reads.Add(1)
+		return "stored", true
+	}))
+	defer cache.Close()
+
+	// Until the cache is ready the value is read through
+	if got := cache.Get(); got != "stored" {
+		t.Errorf("Get() before the first load = %q, want %q", got, "stored")
+	}
+	if got, err := cache.GetWithError(); got != "stored" || !errors.Is(err, ErrNotReady) {
+		t.Errorf("GetWithError() before the first load = (%q, %v), want (%q, %v)", got, err, "stored", ErrNotReady)
+	}
+
+	// and never again afterwards
+	close(release)
+	if err := cache.WaitReady(ctx); err != nil {
+		t.Fatalf("WaitReady() = %v", err)
+	}
+	before := reads.Load()
+	if got, err := cache.GetWithError(); got != "primary" || err != nil {
+		t.Errorf("GetWithError() after the first load = (%q, %v), want (%q, nil)", got, err, "primary")
+	}
+	if got := cache.Get(); got != "primary" {
+		t.Errorf("Get() after the first load = %q, want %q", got, "primary")
+	}
+	if got := reads.Load(); got != before {
+		t.Errorf("Read-through calls after the first load = %v, want none", got-before)
+	}
+
+	// A read-through miss returns the cached value
+	missing := New(ctx, time.Hour, func() (int, error) {
+		return 0, errors.New("source is down")
+	}, WithReadThrough(func() (int, bool) {
+		return 0, false
+	}))
+	defer missing.Close()
+	if _, err := missing.GetWithError(); !errors.Is(err, ErrNotReady) {
+		t.Errorf("GetWithError() with a read-through miss = %v, want %v", err, ErrNotReady)
+	}
+}
+
+func TestReady(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var fail atomic.Bool
+	fail.Store(true)
+	updateFunc := func() (int, error) {
+		if fail.Load() {
+			return 0, errors.New("update failed")
+		}
+		return 1, nil
+	}
+
+	cache := New(ctx, 10*time.Millisecond, updateFunc)
+	defer cache.Close()
+
+	// The initial update failed, so the cache is not ready
+	if cache.Ready() {
+		t.Errorf("Ready() after failed initial update = true, want false")
+	}
+	if _, err := cache.GetWithError(); !errors.Is(err, ErrNotReady) {
+		t.Errorf("GetWithError() after failed initial update = %v, want %v", err, ErrNotReady)
+	}
+	waitCtx, waitCancel := context.WithTimeout(ctx, 30*time.Millisecond)
+	defer waitCancel()
+	if err := cache.WaitReady(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitReady() = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The update loop makes it ready once the source recovers
+	fail.Store(false)
+	waitCtx, waitCancel = context.WithTimeout(ctx, 500*time.Millisecond)
+	defer waitCancel()
+	if err := cache.WaitReady(waitCtx); err != nil {
+		t.Fatalf("WaitReady() = %v, want nil", err)
+	}
+	if !cache.Ready() {
+		t.Errorf("Ready() after successful update = false, want true")
+	}
+
+	// Later failures do not make it not ready again
+	fail.Store(true)
+	cache.Update()
+	if _, err := cache.GetWithError(); err == nil || errors.Is(err, ErrNotReady) {
+		t.Errorf("GetWithError() after a failure once ready = %v, want the update error", err)
+	}
+}
+
+func TestUpdatePanic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shouldPanic := false
+	cache := New(ctx, time.Hour, func() (int, error) {
+		if shouldPanic {
+			panic("boom")
+		}
+		return 1, nil
+	})
+	defer cache.Close()
+
+	// A panic becomes the update error and the value is kept
+	shouldPanic = true
+	if err := cache.ForceUpdate(); !errors.Is(err, ErrPanic) {
+		t.Errorf("ForceUpdate() = %v, want %v", err, ErrPanic)
+	}
+	if got, err := cache.GetWithError(); got != 1 || !errors.Is(err, ErrPanic) {
+		t.Errorf("GetWithError() after panic = (%v, %v), want (%v, %v)", got, err, 1, ErrPanic)
+	}
+
+	// The global update survives it as well
+	GlobalCacheUpdate()
+}
+
+type testLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *testLogger) Printf(format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func TestWithLogger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fail := false
+	logger := &testLogger{}
+	cache := New(ctx, time.Hour, func() (int, error) {
+		if fail {
+			return 0, errors.New("source is down")
+		}
+		return 1, nil
+	}, WithLogger[int](logger), WithName[int]("test-logger"))
+	defer cache.Close()
+
+	fail = true
+	cache.Update()
+
+	if len(logger.messages) != 2 {
+		t.Fatalf("Logged messages = %q, want 2 messages", logger.messages)
+	}
+	if msg := logger.messages[0]; !strings.Contains(msg, "recached[test-logger]: updated in") {
+		t.Errorf("Success message = %q", msg)
+	}
+	if msg := logger.messages[1]; !strings.Contains(msg, "update failed") || !strings.Contains(msg, "source is down") {
+		t.Errorf("Failure message = %q", msg)
+	}
+
+	// A nil logger disables logging instead of panicking
+	silent := New(ctx, time.Hour, func() (int, error) {
+		return 1, nil
+	}, WithLogger[int](nil))
+	defer silent.Close()
+	if err := silent.ForceUpdate(); err != nil {
+		t.Errorf("ForceUpdate() with a nil logger = %v, want nil", err)
+	}
+}
+
+func TestSetDefaultLogger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := &testLogger{}
+	SetDefaultLogger(logger)
+	t.Cleanup(func() { SetDefaultLogger(nil) })
+
+	updateFunc := func() (int, error) {
+		return 1, nil
+	}
+
+	// New caches pick the default logger up, WithLogger overrides it
+	byDefault := New(ctx, time.Hour, updateFunc, WithName[int]("test-default-logger"))
+	defer byDefault.Close()
+	own := &testLogger{}
+	overridden := New(ctx, time.Hour, updateFunc, WithLogger[int](own))
+	defer overridden.Close()
+	silent := New(ctx, time.Hour, updateFunc, WithLogger[int](nil))
+	defer silent.Close()
+
+	// Caches created before keep their logger
+	SetDefaultLogger(nil)
+	for _, cache := range []ReCached[int]{byDefault, overridden, silent} {
+		cache.Update()
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	if len(logger.messages) != 2 || !strings.Contains(logger.messages[1], "recached[test-default-logger]: updated in") {
+		t.Errorf("Messages of the default logger = %q, want the 2 updates of the cache using it", logger.messages)
+	}
+	own.mu.Lock()
+	defer own.mu.Unlock()
+	if len(own.messages) != 2 {
+		t.Errorf("Messages of the logger set by WithLogger = %q, want 2 messages", own.messages)
+	}
+}
+
+func TestSetDefaultLoggerConcurrent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	t.Cleanup(func() { SetDefaultLogger(nil) })
+
+	// Setting the default logger while caches are created is safe
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				SetDefaultLogger(&testLogger{})
+				return
+			}
+			cache := New(ctx, time.Hour, func() (int, error) {
+				return i, nil
+			}, WithoutGlobalRegistration[int]())
+			cache.Close()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestStats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updateErr := errors.New("update failed")
+	fail := false
+	cache := New(ctx, time.Hour, func() (int, error) {
+		time.Sleep(5 * time.Millisecond)
+		if fail {
+			return 0, updateErr
+		}
+		return 1, nil
+	})
+	defer cache.Close()
+
+	cache.Update()
+	fail = true
+	cache.Update()
+
+	stats := cache.Stats()
+	if stats.Updates != 3 {
+		t.Errorf("Stats().Updates = %v, want %v", stats.Updates, 3)
+	}
+	if stats.Failures != 1 {
+		t.Errorf("Stats().Failures = %v, want %v", stats.Failures, 1)
+	}
+	if stats.LastDuration < 5*time.Millisecond {
+		t.Errorf("Stats().LastDuration = %v, want at least %v", stats.LastDuration, 5*time.Millisecond)
+	}
+	if !stats.LastSuccess.Equal(cache.LastUpdated()) {
+		t.Errorf("Stats().LastSuccess = %v, want %v", stats.LastSuccess, cache.LastUpdated())
+	}
+	if !errors.Is(stats.LastError, updateErr) {
+		t.Errorf("Stats().LastError = %v, want %v", stats.LastError, updateErr)
+	}
+}
+
+func TestConsecutiveFailures(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	var fail atomic.Bool
+	cache := New(ctx, time.Hour, func() (int, error) {
+		if fail.Load() {
+			return 0, errors.New("update failed")
+		}
+		return 1, nil
+	}, WithClock[int](clock))
+	defer cache.Close()
+
+	if stats := cache.Stats(); stats.ConsecutiveFailures != 0 || !stats.FailingSince.IsZero() {
+		t.Errorf("Stats() while healthy = (%v, %v), want no failures", stats.ConsecutiveFailures, stats.FailingSince)
+	}
+
+	// Failures in a row count from the first one
+	fail.Store(true)
+	first := clock.Now()
+	cache.Update()
+	clock.Advance(time.Minute)
+	cache.Update()
+	if stats := cache.Stats(); stats.ConsecutiveFailures != 2 || !stats.FailingSince.Equal(first) {
+		t.Errorf("Stats() while failing = (%v, %v), want (2, %v)", stats.ConsecutiveFailures, stats.FailingSince, first)
+	}
+
+	// A success resets both
+	fail.Store(false)
+	cache.Update()
+	if stats := cache.Stats(); stats.ConsecutiveFailures != 0 || !stats.FailingSince.IsZero() || stats.Failures != 2 {
+		t.Errorf("Stats() after a success = %+v, want no consecutive failures", stats)
+	}
+}
+
+func TestWithMaxStaleness(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updateErr := errors.New("update failed")
+	fail := false
+	updateFunc := func() (int, error) {
+		if fail {
+			return 0, updateErr
+		}
+		return 1, nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc, WithMaxStaleness[int](20*time.Millisecond))
+	defer cache.Close()
+	zeroing := New(ctx, time.Hour, updateFunc, WithMaxStaleness[int](20*time.Millisecond), WithZeroOnStale[int]())
+	defer zeroing.Close()
+
+	// A fresh value has no error
+	if got, err := cache.GetWithError(); got != 1 || err != nil {
+		t.Errorf("GetWithError() = (%v, %v), want (%v, nil)", got, err, 1)
+	}
+
+	// A failing source makes the value stale once it is too old
+	fail = true
+	cache.Update()
+	zeroing.Update()
+	time.Sleep(30 * time.Millisecond)
+
+	got, err := cache.GetWithError()
+	if got != 1 || !errors.Is(err, ErrStale) || !errors.Is(err, updateErr) {
+		t.Errorf("GetWithError() when stale = (%v, %v), want (%v, %v)", got, err, 1, ErrStale)
+	}
+	if got := cache.Get(); got != 1 {
+		t.Errorf("Get() when stale = %v, want %v", got, 1)
+	}
+	if got := zeroing.Get(); got != 0 {
+		t.Errorf("Get() when stale with WithZeroOnStale = %v, want zero value", got)
+	}
+
+	// A successful update makes it fresh again
+	fail = false
+	cache.Update()
+	if _, err := cache.GetWithError(); err != nil {
+		t.Errorf("GetWithError() after successful Update() = %v, want nil", err)
+	}
+}
+
+func TestWithTTL(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	updateErr := errors.New("update failed")
+	fail := false
+	updateFunc := func() (int, error) {
+		if fail {
+			return 0, updateErr
+		}
+		return 1, nil
+	}
+
+	// Polling often does not extend the time the value is trusted
+	cache := New(ctx, time.Second, updateFunc, WithClock[int](clock), WithTTL[int](time.Minute))
+	defer cache.Close()
+	cache.Pause()
+
+	clock.Advance(59 * time.Second)
+	if got, err := cache.GetWithError(); got != 1 || err != nil {
+		t.Errorf("GetWithError() before TTL = (%v, %v), want (%v, nil)", got, err, 1)
+	}
+
+	// An expired value is not returned anymore
+	fail = true
+	cache.Update()
+	clock.Advance(2 * time.Second)
+	got, err := cache.GetWithError()
+	if got != 0 || !errors.Is(err, ErrExpired) || !errors.Is(err, updateErr) {
+		t.Errorf("GetWithError() after TTL = (%v, %v), want (0, %v)", got, err, ErrExpired)
+	}
+	if got := cache.Get(); got != 0 {
+		t.Errorf("Get() after TTL = %v, want zero value", got)
+	}
+
+	// A successful update renews it
+	fail = false
+	cache.Update()
+	if got, err := cache.GetWithError(); got != 1 || err != nil {
+		t.Errorf("GetWithError() after successful Update() = (%v, %v), want (%v, nil)", got, err, 1)
+	}
+}
+
+func TestGetOK(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	var fail atomic.Bool
+	fail.Store(true)
+	updateFunc := func() (int, error) {
+		if fail.Load() {
+			return 0, errors.New("update failed")
+		}
+		return 1, nil
+	}
+
+	cache := New(ctx, time.Hour, updateFunc, WithClock[int](clock), WithTTL[int](time.Minute))
+	defer cache.Close()
+
+	// Not fresh before the first successful update
+	if got, ok := cache.GetOK(); got != 0 || ok {
+		t.Errorf("GetOK() before the first update = (%v, %v), want (0, false)", got, ok)
+	}
+
+	fail.Store(false)
+	cache.Update()
+	if got, ok := cache.GetOK(); got != 1 || !ok {
+		t.Errorf("GetOK() after an update = (%v, %v), want (%v, true)", got, ok, 1)
+	}
+
+	// An expired value is not fresh anymore
+	clock.Advance(2 * time.Minute)
+	if got, ok := cache.GetOK(); got != 0 || ok {
+		t.Errorf("GetOK() after TTL = (%v, %v), want (0, false)", got, ok)
+	}
+
+	// Without a TTL a loaded value stays fresh
+	plain := New(ctx, time.Hour, updateFunc, WithClock[int](clock))
+	defer plain.Close()
+	clock.Advance(24 * time.Hour)
+	if got, ok := plain.GetOK(); got != 1 || !ok {
+		t.Errorf("GetOK() without TTL = (%v, %v), want (%v, true)", got, ok, 1)
+	}
+}
+
+// BenchmarkGet measures the lock-free read of Get and the locked one needed for expiry
+func BenchmarkGet(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, bench := range getBenchmarks {
+		b.Run(bench.name, func(b *testing.B) {
+			cache := New(ctx, time.Hour, benchmarkUpdate, append(bench.opts, WithoutGlobalRegistration[int]())...)
+			defer cache.Close()
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				cache.Get()
+			}
+		})
+	}
+}
+
+// BenchmarkConcurrentGet is like BenchmarkGet, but with parallel readers contending with a writer
+func BenchmarkConcurrentGet(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, bench := range getBenchmarks {
+		b.Run(bench.name, func(b *testing.B) {
+			cache := New(ctx, time.Hour, benchmarkUpdate, append(bench.opts, WithoutGlobalRegistration[int]())...)
+			defer cache.Close()
+
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				for {
+					select {
+					case <-done:
+						return
+					default:
+						cache.Set(1)
+					}
+				}
+			}()
+
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					cache.Get()
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkUpdate measures an explicit update storing a new value
+func BenchmarkUpdate(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cache := New(ctx, time.Hour, benchmarkUpdate, WithoutGlobalRegistration[int]())
+	defer cache.Close()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cache.Update()
+	}
+}
+
+// BenchmarkUpdateLoopOverhead measures an update done by the update loop, from Invalidate until the value is stored
+func BenchmarkUpdateLoopOverhead(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	value := 0
+	updated := make(chan struct{}, 1)
+	cache := New(ctx, time.Hour, func() (int, error) {
+		value++
+		return value, nil
+	}, WithoutGlobalRegistration[int](), WithOnUpdate(func(old, new int) {
+		updated <- struct{}{}
+	}))
+	defer cache.Close()
+	<-updated
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cache.Invalidate()
+		<-updated
+	}
+}
+
+var getBenchmarks = []struct {
+	name string
+	opts []Option[int]
+}{
+	{"atomic", nil},
+	{"locked", []Option[int]{WithTTL[int](time.Hour)}},
+}
+
+func benchmarkUpdate() (int, error) {
+	return 1, nil
+}
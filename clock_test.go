@@ -0,0 +1,150 @@
+package recached
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when advanced
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+	created chan struct{}
+	resets  chan struct{}
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		created: make(chan struct{}, 100),
+		resets:  make(chan struct{}, 100),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTicker{clock: c, c: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	c.created <- struct{}{}
+	return t
+}
+
+// Advance moves the clock forward and fires the tickers that are due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		if t.stopped || t.next.After(c.now) {
+			continue
+		}
+		select {
+		case t.c <- c.now:
+		default:
+		}
+		for !t.next.After(c.now) {
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+// advanceUpdate moves the clock forward by d, which must trigger exactly one automatic update of cache,
+// and blocks until that update has completed, so tests do not need to sleep
+func advanceUpdate[T any](t *testing.T, clock *fakeClock, cache ReCached[T], d time.Duration) {
+	t.Helper()
+
+	before := cache.Stats().Updates
+	clock.Advance(d)
+	deadline := time.Now().Add(time.Second)
+	for cache.Stats().Updates == before {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for an automatic update after advancing the clock by %v", d)
+		}
+		runtime.Gosched()
+	}
+}
+
+type fakeTicker struct {
+	clock   *fakeClock
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.period = d
+	t.next = t.clock.now.Add(d)
+	t.stopped = false
+	select {
+	case t.clock.resets <- struct{}{}:
+	default:
+	}
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stopped = true
+}
+
+func TestWithClock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	updateCh := make(chan int, 10)
+	value := 0
+	updateFunc := func() (int, error) {
+		value++
+		updateCh <- value
+		return value, nil
+	}
+
+	cache := New(ctx, time.Minute, updateFunc, WithClock[int](clock))
+	defer cache.Close()
+	<-updateCh
+	<-clock.created
+
+	// Timestamps come from the clock
+	if got := cache.LastUpdated(); !got.Equal(clock.Now()) {
+		t.Errorf("LastUpdated() = %v, want %v", got, clock.Now())
+	}
+	clock.Advance(30 * time.Second)
+	if got := cache.Age(); got != 30*time.Second {
+		t.Errorf("Age() = %v, want %v", got, 30*time.Second)
+	}
+
+	// Every full period of the clock triggers exactly one update
+	for i := 2; i <= 4; i++ {
+		clock.Advance(30 * time.Second)
+		select {
+		case got := <-updateCh:
+			if got != i {
+				t.Errorf("Update %d returned %v", i, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for update %d", i)
+		}
+		clock.Advance(30 * time.Second)
+	}
+}
@@ -0,0 +1,67 @@
+package recached
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ReCachedComputed is a cache of a value computed from an input, see NewComputed
+type ReCachedComputed[T any, I comparable] interface {
+	ReCached[T]
+	// Input returns the input the current value was computed from, the zero value until one was computed
+	Input() I
+}
+
+type reCachedComputed[T any, I comparable] struct {
+	*reCached[T]
+	input *computedInput[I]
+}
+
+// computedInput is the input the value of a computed cache was computed from
+type computedInput[I comparable] struct {
+	mu    sync.Mutex
+	value I
+	ok    bool
+}
+
+// NewComputed creates a cache of a value computed from the input returned by inputFn.
+// Every period inputFn is polled, and computeFn is only called when the input differs from the one
+// the current value was computed from. An unchanged input keeps the value like NewConditional does.
+// If computeFn fails, the input is compared to the last successfully computed one next time
+func NewComputed[T any, I comparable](ctx context.Context, period time.Duration, inputFn func() I, computeFn func(input I) (T, error), opts ...Option[T]) ReCachedComputed[T, I] {
+	input := new(computedInput[I])
+	var cache *reCached[T]
+	cache = newReCached(ctx, period, func(context.Context) (T, error) {
+		next := inputFn()
+
+		input.mu.Lock()
+		unchanged := input.ok && input.value == next
+		input.mu.Unlock()
+		// A reset cache has no value to keep, so it is computed even from an unchanged input
+		if unchanged && cache.Ready() {
+			var zero T
+			return zero, errNotModified
+		}
+
+		value, err := computeFn(next)
+		if err == nil {
+			input.mu.Lock()
+			input.value, input.ok = next, true
+			input.mu.Unlock()
+		}
+		return value, err
+	}, opts...)
+
+	started, err := cache.run(false)
+	if err != nil {
+		panic(err)
+	}
+	return reCachedComputed[T, I]{reCached: started, input: input}
+}
+
+func (r reCachedComputed[T, I]) Input() I {
+	r.input.mu.Lock()
+	defer r.input.mu.Unlock()
+	return r.input.value
+}
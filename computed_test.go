@@ -0,0 +1,70 @@
+package recached
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNewComputed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	input := 1
+	var computations int
+	var fail bool
+	cache := NewComputed(ctx, time.Hour, func() int {
+		return input
+	}, func(input int) (string, error) {
+		computations++
+		if fail {
+			return "", errors.New("compute failed")
+		}
+		return fmt.Sprint("value ", input), nil
+	})
+	defer cache.Close()
+
+	if got := cache.Get(); got != "value 1" {
+		t.Errorf("Get() = %q, want %q", got, "value 1")
+	}
+	if got := cache.Input(); got != 1 {
+		t.Errorf("Input() = %v, want %v", got, 1)
+	}
+
+	// An unchanged input is not computed again
+	cache.Update()
+	if computations != 1 {
+		t.Errorf("Computations with an unchanged input = %v, want %v", computations, 1)
+	}
+	if version := cache.Version(); version != 1 {
+		t.Errorf("Version() with an unchanged input = %v, want %v", version, 1)
+	}
+
+	// A changed input is
+	input = 2
+	cache.Update()
+	if got := cache.Get(); got != "value 2" || computations != 2 {
+		t.Errorf("Get() with a changed input = %q after %v computations, want %q after 2", got, computations, "value 2")
+	}
+
+	// A failed computation keeps the value and the input, so the next poll computes again
+	input, fail = 3, true
+	cache.Update()
+	if got := cache.Input(); got != 2 {
+		t.Errorf("Input() after a failed computation = %v, want %v", got, 2)
+	}
+	fail = false
+	cache.Update()
+	if got := cache.Get(); got != "value 3" || cache.Input() != 3 {
+		t.Errorf("Get() after a retried computation = %q, want %q", got, "value 3")
+	}
+
+	// A reset cache is computed even from an unchanged input
+	cache.Reset()
+	cache.Update()
+	if got := cache.Get(); got != "value 3" {
+		t.Errorf("Get() after Reset() = %q, want %q", got, "value 3")
+	}
+}
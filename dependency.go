@@ -0,0 +1,105 @@
+package recached
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrCycle is returned by DependsOn when the dependency would make a cache depend on itself
+var ErrCycle = errors.New("recached: dependency cycle")
+
+// Upstream is a cache other caches can depend on, see ReCached.DependsOn.
+// It is implemented by the caches of this package whatever their value type
+type Upstream interface {
+	Invalidate()
+	edges() *edges
+}
+
+// edges are the dependencies of a cache, guarded by dependencyMu
+type edges struct {
+	upstreams   []Upstream
+	downstreams []Upstream
+	// active is set while there are downstreams, so updates can check it without dependencyMu
+	active atomic.Bool
+}
+
+// same reports whether a and b are the same cache, also if one of them is wrapped, e.g. by NewOrdered
+func same(a, b Upstream) bool {
+	return a.edges() == b.edges()
+}
+
+// dependencyMu guards the dependency graph of all caches, so cycles are detected consistently
+var dependencyMu sync.Mutex
+
+func (r *reCached[T]) edges() *edges {
+	return &r.deps
+}
+
+func (r *reCached[T]) DependsOn(upstream Upstream) error {
+	dependencyMu.Lock()
+	defer dependencyMu.Unlock()
+
+	// Checked under dependencyMu, so Close cannot remove the dependencies before they are added
+	r.mu.RLock()
+	closed := r.closed
+	r.mu.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+
+	if reaches(upstream, r) {
+		return ErrCycle
+	}
+	if slices.ContainsFunc(r.deps.upstreams, func(u Upstream) bool { return same(u, upstream) }) {
+		return nil
+	}
+	r.deps.upstreams = append(r.deps.upstreams, upstream)
+	up := upstream.edges()
+	up.downstreams = append(up.downstreams, r)
+	up.active.Store(true)
+	return nil
+}
+
+// reaches reports whether from is target or depends on it, dependencyMu must be held
+func reaches(from, target Upstream) bool {
+	if same(from, target) {
+		return true
+	}
+	for _, up := range from.edges().upstreams {
+		if reaches(up, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidateDownstreams invalidates the caches depending on r after its value changed
+func (r *reCached[T]) invalidateDownstreams() {
+	dependencyMu.Lock()
+	downstreams := slices.Clone(r.deps.downstreams)
+	dependencyMu.Unlock()
+
+	for _, down := range downstreams {
+		down.Invalidate()
+	}
+}
+
+// removeDependencies removes r from the dependency graph when it is closed
+func (r *reCached[T]) removeDependencies() {
+	dependencyMu.Lock()
+	defer dependencyMu.Unlock()
+
+	for _, up := range r.deps.upstreams {
+		e := up.edges()
+		e.downstreams = slices.DeleteFunc(e.downstreams, func(u Upstream) bool { return same(u, r) })
+		e.active.Store(len(e.downstreams) > 0)
+	}
+	for _, down := range r.deps.downstreams {
+		e := down.edges()
+		e.upstreams = slices.DeleteFunc(e.upstreams, func(u Upstream) bool { return same(u, r) })
+	}
+	r.deps.upstreams, r.deps.downstreams = nil, nil
+	r.deps.active.Store(false)
+}
@@ -0,0 +1,92 @@
+package recached
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDependsOn(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var rows atomic.Int64
+	rows.Store(2)
+	raw := New(ctx, time.Hour, func() ([]int64, error) {
+		return make([]int64, rows.Load()), nil
+	}, WithoutGlobalRegistration[[]int64]())
+	defer raw.Close()
+
+	// The derived cache of another type reads the upstream
+	report := New(ctx, time.Hour, func() (int, error) {
+		return len(raw.Get()), nil
+	}, WithoutGlobalRegistration[int]())
+	defer report.Close()
+
+	if err := report.DependsOn(raw); err != nil {
+		t.Fatalf("DependsOn() = %v, want nil", err)
+	}
+
+	// A change of the upstream invalidates the dependent
+	rows.Store(5)
+	raw.Update()
+	waitFor(t, func() bool { return report.Get() == 5 }, "the dependent to be refreshed")
+
+	// Cycles are rejected
+	if err := raw.DependsOn(report); !errors.Is(err, ErrCycle) {
+		t.Errorf("DependsOn() closing a cycle = %v, want %v", err, ErrCycle)
+	}
+	if err := raw.DependsOn(raw); !errors.Is(err, ErrCycle) {
+		t.Errorf("DependsOn() on itself = %v, want %v", err, ErrCycle)
+	}
+
+	// Closing the dependent removes the dependency, so the upstream may depend on it now
+	report.Close()
+	if err := raw.DependsOn(report); err != nil {
+		t.Errorf("DependsOn() after closing the dependent = %v, want nil", err)
+	}
+	if err := report.DependsOn(raw); !errors.Is(err, ErrClosed) {
+		t.Errorf("DependsOn() of a closed cache = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestDependsOnWrapped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Wrapped caches are the same graph node as the caches they wrap
+	price := NewOrdered(ctx, time.Hour, func() (int, error) {
+		return 1, nil
+	}, WithoutGlobalRegistration[int]())
+	defer price.Close()
+	report := New(ctx, time.Hour, func() (int, error) {
+		return price.Get() * 2, nil
+	}, WithoutGlobalRegistration[int]())
+	defer report.Close()
+
+	if err := report.DependsOn(price); err != nil {
+		t.Fatalf("DependsOn() = %v, want nil", err)
+	}
+	if err := price.DependsOn(report); !errors.Is(err, ErrCycle) {
+		t.Errorf("DependsOn() closing a cycle through a wrapped cache = %v, want %v", err, ErrCycle)
+	}
+
+	price.Set(3)
+	price.Update()
+	waitFor(t, func() bool { return report.Get() == 2 }, "the dependent to be refreshed")
+}
+
+// waitFor polls cond until it holds, failing the test after a second
+func waitFor(t *testing.T, cond func() bool, what string) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,37 @@
+package recached
+
+import "sync"
+
+// flight coalesces concurrent calls into a single execution
+type flight struct {
+	mu   sync.Mutex
+	call *flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	err  error
+}
+
+// do runs fn, or if a call is already in flight, waits for it and returns its error instead
+func (f *flight) do(fn func() error) error {
+	f.mu.Lock()
+	if c := f.call; c != nil {
+		f.mu.Unlock()
+		<-c.done
+		return c.err
+	}
+	c := &flightCall{done: make(chan struct{})}
+	f.call = c
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.call = nil
+		f.mu.Unlock()
+		close(c.done)
+	}()
+
+	c.err = fn()
+	return c.err
+}
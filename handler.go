@@ -0,0 +1,101 @@
+package recached
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Handler returns an http.Handler to inspect and refresh the caches in the global registry:
+//
+//	GET                list the registered caches as JSON, see ListCaches
+//	POST ?name=users   update the cache registered as users, see WithName
+//	POST ?tag=config   update the caches tagged config, see RefreshTag
+//	POST               update all registered caches, see GlobalCacheUpdateContext
+//
+// A successful POST responds with 204 No Content, a failed update with 502 Bad Gateway and the error.
+// Updates skipped because of WithMinInterval count as successful
+// The handler is not mounted anywhere by itself, and it does no authentication
+func Handler() http.Handler {
+	return http.HandlerFunc(serveCaches)
+}
+
+// cacheJSON is the JSON form of a CacheInfo
+type cacheJSON struct {
+	Name        string     `json:"name,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Ready       bool       `json:"ready"`
+	AgeSeconds  float64    `json:"age_seconds"`
+	Version     uint64     `json:"version"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	Updates     uint64     `json:"updates"`
+	Failures    uint64     `json:"failures"`
+}
+
+func serveCaches(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		listCaches(w)
+	case http.MethodPost:
+		refreshCaches(w, req)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func listCaches(w http.ResponseWriter) {
+	infos := ListCaches()
+	caches := make([]cacheJSON, 0, len(infos))
+	for _, info := range infos {
+		c := cacheJSON{
+			Name:       info.Name,
+			Tags:       info.Tags,
+			Ready:      info.Ready,
+			AgeSeconds: info.Age.Seconds(),
+			Version:    info.Version,
+			Updates:    info.Stats.Updates,
+			Failures:   info.Stats.Failures,
+		}
+		if info.LastError != nil {
+			c.LastError = info.LastError.Error()
+		}
+		if !info.Stats.LastSuccess.IsZero() {
+			c.LastSuccess = &info.Stats.LastSuccess
+		}
+		caches = append(caches, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(caches)
+}
+
+func refreshCaches(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	var err error
+	switch {
+	case query.Has("name"):
+		cache, ok := lookup(query.Get("name"))
+		if !ok {
+			http.Error(w, "cache not found", http.StatusNotFound)
+			return
+		}
+		// The value was updated recently, so a throttled update is not a failure, like in global updates
+		if err = cache.ForceUpdate(); errors.Is(err, ErrThrottled) {
+			err = nil
+		}
+	case query.Has("tag"):
+		err = RefreshTag(query.Get("tag"))
+	default:
+		err = GlobalCacheUpdateContext(req.Context())
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
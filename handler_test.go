@@ -0,0 +1,93 @@
+package recached
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int64
+	users := New(ctx, time.Hour, func() (int64, error) {
+		return calls.Add(1), nil
+	}, WithName[int64]("users"), WithTags[int64]("db"))
+	defer users.Close()
+	failing := New(ctx, time.Hour, func() (int, error) {
+		return 0, errors.New("update failed")
+	}, WithName[int]("failing"))
+	defer failing.Close()
+
+	handler := Handler()
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+
+	// GET lists the caches
+	rec := serve(http.MethodGet, "/")
+	var caches []cacheJSON
+	if err := json.NewDecoder(rec.Body).Decode(&caches); err != nil {
+		t.Fatalf("Decoding the GET response failed: %v", err)
+	}
+	if len(caches) != 2 || caches[0].Name != "failing" || caches[0].LastError != "update failed" ||
+		caches[1].Name != "users" || !caches[1].Ready || caches[1].Updates != 1 || caches[1].LastSuccess == nil {
+		t.Errorf("GET listed %+v", caches)
+	}
+
+	// POST refreshes a named cache, a tag or everything
+	if rec := serve(http.MethodPost, "/?name=users"); rec.Code != http.StatusNoContent || users.Get() != 2 {
+		t.Errorf("POST ?name=users = %v, Get() = %v, want %v and %v", rec.Code, users.Get(), http.StatusNoContent, 2)
+	}
+	if rec := serve(http.MethodPost, "/?tag=db"); rec.Code != http.StatusNoContent || users.Get() != 3 {
+		t.Errorf("POST ?tag=db = %v, Get() = %v, want %v and %v", rec.Code, users.Get(), http.StatusNoContent, 3)
+	}
+	if rec := serve(http.MethodPost, "/"); rec.Code != http.StatusBadGateway || users.Get() != 4 {
+		t.Errorf("POST = %v, Get() = %v, want %v and %v", rec.Code, users.Get(), http.StatusBadGateway, 4)
+	}
+	if rec := serve(http.MethodPost, "/?name=missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("POST ?name=missing = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+	if rec := serve(http.MethodDelete, "/"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE = %v, want %v", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestHandlerThrottled(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int64
+	throttled := New(ctx, time.Hour, func() (int64, error) {
+		return calls.Add(1), nil
+	}, WithName[int64]("throttled"), WithMinInterval[int64](time.Hour))
+	defer throttled.Close()
+
+	// A cache updated recently is skipped, which is not a failure
+	handler := Handler()
+	for _, target := range []string{"/?name=throttled", "/"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, nil))
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("POST %s of a throttled cache = %v %q, want %v", target, rec.Code, rec.Body, http.StatusNoContent)
+		}
+	}
+	if err := GlobalCacheUpdateContext(ctx); err != nil {
+		t.Errorf("GlobalCacheUpdateContext() with a throttled cache = %v, want nil", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Update function calls = %v, want %v", got, 1)
+	}
+}
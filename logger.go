@@ -0,0 +1,30 @@
+package recached
+
+import "sync"
+
+// Logger receives messages about cache updates
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+var (
+	defaultLoggerMu sync.RWMutex
+	defaultLogger   Logger
+)
+
+// SetDefaultLogger sets the logger of the caches created afterwards, unless they set their own
+// with WithLogger, e.g. to route the logs of all caches in one place at startup. Caches already
+// created keep their logger. A nil logger disables logging again, which is the default.
+// It is safe to call concurrently with the constructors
+func SetDefaultLogger(l Logger) {
+	defaultLoggerMu.Lock()
+	defer defaultLoggerMu.Unlock()
+	defaultLogger = l
+}
+
+// currentDefaultLogger returns the logger set by SetDefaultLogger
+func currentDefaultLogger() Logger {
+	defaultLoggerMu.RLock()
+	defer defaultLoggerMu.RUnlock()
+	return defaultLogger
+}
@@ -0,0 +1,239 @@
+package recached
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ReCachedMap is a cache of values per key, all refreshed by a single update loop
+type ReCachedMap[K comparable, V any] interface {
+	// Get returns the value for key. The first Get of a key loads it and blocks until the load finishes
+	Get(key K) V
+	// GetWithError is like Get, but also returns the error of the last update of key, if it failed
+	GetWithError(key K) (V, error)
+	// GetAll returns a copy of the values of all keys like Get, without loading any.
+	// Keys without a value yet, e.g. because their first load failed, are left out
+	GetAll() map[K]V
+	// MultiGet returns the value and the metadata of every key in keys in a single read, without loading any,
+	// e.g. for dashboards. Keys not in the map are included with Present unset
+	MultiGet(keys ...K) map[K]EntryMeta[V]
+	// Load adds key to the map, if needed, and updates its value synchronously
+	Load(key K) error
+	// Update updates the value for key synchronously, adding key to the map if needed
+	Update(key K)
+	// Delete removes key from the map, so it is not updated anymore
+	Delete(key K)
+	// Close stops the update loop. The last values stay available via Get
+	Close()
+}
+
+// EntryMeta is the value of a key with its metadata, see ReCachedMap.MultiGet
+type EntryMeta[V any] struct {
+	// Value is the value of the key like Get returns it, the zero value if the key has none
+	Value V
+	// Present reports whether the key is in the map. A present key has no value yet if its first load failed,
+	// then Version is 0 and LastError is set
+	Present bool
+	Meta
+}
+
+type reCachedMap[K comparable, V any] struct {
+	mu      sync.RWMutex
+	entries map[K]*reCached[V]
+	closed  bool
+	period  time.Duration
+	loader  func(key K) (V, error)
+	opts    []Option[V]
+	clock   Clock
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewMap creates a map cache loading values with loader. Keys are added on first access or via Load,
+// and every period all known keys are updated concurrently. A period of 0 or less means no automatic updates.
+// The options are applied to the cache of every key, options about the global registry have no effect,
+// since the map is not registered. The clock set by WithClock also drives the update loop
+func NewMap[K comparable, V any](ctx context.Context, period time.Duration, loader func(key K) (V, error), opts ...Option[V]) ReCachedMap[K, V] {
+	// The options are applied to a throwaway cache to find the clock of the loop
+	base := newReCached[V](ctx, period, nil, opts...)
+	base.cancel()
+
+	ctx, cancel := context.WithCancel(ctx)
+	m := &reCachedMap[K, V]{
+		entries: make(map[K]*reCached[V]),
+		period:  period,
+		loader:  loader,
+		opts:    opts,
+		clock:   base.clock,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	go m.updateLoop()
+	return m
+}
+
+// entry returns the cache of key, creating it if needed. It returns nil if the map is closed
+func (m *reCachedMap[K, V]) entry(key K) *reCached[V] {
+	m.mu.RLock()
+	e, ok := m.entries[key]
+	closed := m.closed
+	m.mu.RUnlock()
+	if ok || closed {
+		return e
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[key]; ok || m.closed {
+		return e
+	}
+	// Every key is a lazy cache without its own update loop, so the first Get loads it exactly once
+	e = newReCached(m.ctx, m.period, ignoreContext(func() (V, error) {
+		return m.loader(key)
+	}), m.opts...)
+	e.registered = false
+	e.lazy = true
+	m.entries[key] = e
+	return e
+}
+
+func (m *reCachedMap[K, V]) Get(key K) V {
+	e := m.entry(key)
+	if e == nil {
+		var zero V
+		return zero
+	}
+	return e.Get()
+}
+
+func (m *reCachedMap[K, V]) GetWithError(key K) (V, error) {
+	e := m.entry(key)
+	if e == nil {
+		var zero V
+		return zero, ErrClosed
+	}
+	return e.GetWithError()
+}
+
+func (m *reCachedMap[K, V]) GetAll() map[K]V {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	values := make(map[K]V, len(m.entries))
+	for key, e := range m.entries {
+		e.mu.RLock()
+		if e.current.Load() != nil {
+			values[key] = e.getLocked()
+		}
+		e.mu.RUnlock()
+	}
+	return values
+}
+
+func (m *reCachedMap[K, V]) MultiGet(keys ...K) map[K]EntryMeta[V] {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := make(map[K]EntryMeta[V], len(keys))
+	for _, key := range keys {
+		e, ok := m.entries[key]
+		if !ok {
+			entries[key] = EntryMeta[V]{}
+			continue
+		}
+		e.mu.RLock()
+		entry := EntryMeta[V]{Present: true, Meta: e.metaLocked()}
+		if e.current.Load() != nil {
+			entry.Value = e.getLocked()
+		}
+		e.mu.RUnlock()
+		entries[key] = entry
+	}
+	return entries
+}
+
+func (m *reCachedMap[K, V]) Load(key K) error {
+	e := m.entry(key)
+	if e == nil {
+		return ErrClosed
+	}
+	return e.ForceUpdate()
+}
+
+func (m *reCachedMap[K, V]) Update(key K) {
+	_ = m.Load(key)
+}
+
+func (m *reCachedMap[K, V]) Delete(key K) {
+	m.mu.Lock()
+	e, ok := m.entries[key]
+	delete(m.entries, key)
+	m.mu.Unlock()
+
+	if ok {
+		e.Close()
+	}
+}
+
+// updateLoop updates all known keys every period until the map is closed
+func (m *reCachedMap[K, V]) updateLoop() {
+	ticker := m.clock.NewTicker(tickerInterval(m.period))
+	defer ticker.Stop()
+	tick := ticker.C()
+	if m.period <= 0 {
+		ticker.Stop()
+		tick = nil
+	}
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-tick:
+			m.updateAll()
+		}
+	}
+}
+
+// updateAll updates all known keys concurrently. Like the update loop of a single cache,
+// it is not affected by WithMinInterval, and failures are kept by the caches of the keys, see GetWithError
+func (m *reCachedMap[K, V]) updateAll() {
+	var wg sync.WaitGroup
+	for _, e := range m.snapshot() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = e.update()
+		}()
+	}
+	wg.Wait()
+}
+
+// snapshot returns the caches of all known keys
+func (m *reCachedMap[K, V]) snapshot() []*reCached[V] {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	caches := make([]*reCached[V], 0, len(m.entries))
+	for _, e := range m.entries {
+		caches = append(caches, e)
+	}
+	return caches
+}
+
+func (m *reCachedMap[K, V]) Close() {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return
+	}
+	m.closed = true
+	m.mu.Unlock()
+
+	m.cancel()
+	for _, e := range m.snapshot() {
+		e.Close()
+	}
+}
@@ -0,0 +1,147 @@
+package recached
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewMap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	loadErr := errors.New("load failed")
+	var mu sync.Mutex
+	loads := make(map[string]int)
+	loader := func(key string) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if key == "bad" {
+			return 0, loadErr
+		}
+		loads[key]++
+		return len(key)*100 + loads[key], nil
+	}
+	loadsOf := func(key string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return loads[key]
+	}
+
+	m := NewMap(ctx, time.Minute, loader, WithClock[int](clock))
+	defer m.Close()
+	<-clock.created
+
+	// Keys are loaded once on first access
+	if got := m.Get("a"); got != 101 {
+		t.Errorf("Get(a) = %v, want %v", got, 101)
+	}
+	if got := m.Get("a"); got != 101 || loadsOf("a") != 1 {
+		t.Errorf("Get(a) again = %v after %v loads, want %v after %v loads", got, loadsOf("a"), 101, 1)
+	}
+	if err := m.Load("bb"); err != nil {
+		t.Errorf("Load(bb) = %v, want nil", err)
+	}
+	if _, err := m.GetWithError("bad"); !errors.Is(err, loadErr) {
+		t.Errorf("GetWithError(bad) = %v, want %v", err, loadErr)
+	}
+
+	// The loop updates all known keys
+	clock.Advance(time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for loadsOf("a") < 2 || loadsOf("bb") < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the update loop, loads = %v and %v", loadsOf("a"), loadsOf("bb"))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := m.Get("bb"); got != 202 {
+		t.Errorf("Get(bb) after the update loop = %v, want %v", got, 202)
+	}
+
+	// Deleted keys are loaded again on next access
+	m.Delete("a")
+	if got := m.Get("a"); got != 103 {
+		t.Errorf("Get(a) after Delete(a) = %v, want %v", got, 103)
+	}
+
+	// A closed map keeps its values, but loads nothing anymore
+	m.Close()
+	if got := m.Get("bb"); got != 202 {
+		t.Errorf("Get(bb) after Close() = %v, want %v", got, 202)
+	}
+	if err := m.Load("c"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Load(c) after Close() = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestMapGetAll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	loadErr := errors.New("load failed")
+	m := NewMap(ctx, time.Hour, func(key string) (int, error) {
+		if key == "bad" {
+			return 0, loadErr
+		}
+		return len(key), nil
+	})
+	defer m.Close()
+
+	m.Get("a")
+	m.Get("bbb")
+	m.Get("bad")
+
+	// Keys without a value are left out
+	all := m.GetAll()
+	if len(all) != 2 || all["a"] != 1 || all["bbb"] != 3 {
+		t.Errorf("GetAll() = %v, want map[a:1 bbb:3]", all)
+	}
+
+	// The result is a copy
+	all["a"] = 100
+	delete(all, "bbb")
+	if got := m.GetAll(); got["a"] != 1 || got["bbb"] != 3 {
+		t.Errorf("GetAll() after changing the returned map = %v, want map[a:1 bbb:3]", got)
+	}
+}
+
+func TestMapMultiGet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	loadErr := errors.New("load failed")
+	m := NewMap(ctx, time.Hour, func(key string) (int, error) {
+		if key == "bad" {
+			return 0, loadErr
+		}
+		return len(key), nil
+	})
+	defer m.Close()
+
+	m.Get("bbb")
+	m.Get("bad")
+	m.Update("bbb")
+
+	entries := m.MultiGet("bbb", "bad", "missing")
+	if len(entries) != 3 {
+		t.Fatalf("MultiGet() = %v, want 3 entries", entries)
+	}
+	if got := entries["bbb"]; !got.Present || got.Value != 3 || got.Version != 2 || got.LastError != nil || got.LastUpdated.IsZero() {
+		t.Errorf("MultiGet() of a loaded key = %+v, want value 3 with version 2", got)
+	}
+	if got := entries["bad"]; !got.Present || got.Version != 0 || !errors.Is(got.LastError, loadErr) {
+		t.Errorf("MultiGet() of a failed key = %+v, want it present without a value and with %v", got, loadErr)
+	}
+	if got := entries["missing"]; got.Present {
+		t.Errorf("MultiGet() of a missing key = %+v, want it not present", got)
+	}
+
+	// Missing keys are not added
+	if got := m.MultiGet("missing")["missing"]; got.Present {
+		t.Errorf("MultiGet() of a missing key again = %+v, want it not present", got)
+	}
+}
@@ -0,0 +1,117 @@
+package recached
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Collector exposes the statistics of the named caches in the global registry
+// in the Prometheus text exposition format:
+//
+//	recached_update_total{cache="name"}                  calls of the update function
+//	recached_failure_total{cache="name"}                 failed calls of the update function
+//	recached_value_age_seconds{cache="name"}             age of the value, only once it has been updated
+//	recached_last_success_timestamp{cache="name"}        unix time of the last successful update, 0 if none
+//
+// Unnamed caches are skipped, since they cannot be told apart, see WithName.
+// A Collector is an http.Handler, so it can be mounted on the endpoint scraped by Prometheus
+type Collector struct{}
+
+// NewCollector returns a Collector for the global registry
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// cacheMetrics is a snapshot of the metrics of a single cache
+type cacheMetrics struct {
+	name        string
+	stats       Stats
+	updated     bool
+	age         float64
+	lastSuccess float64
+}
+
+// Collect writes the current metrics of all named registered caches to w
+func (c *Collector) Collect(w io.Writer) error {
+	var caches []cacheMetrics
+	for _, cache := range registeredCaches() {
+		name := cache.cacheName()
+		if name == "" {
+			continue
+		}
+		m := cacheMetrics{name: name, stats: cache.Stats(), updated: !cache.LastUpdated().IsZero()}
+		if m.updated {
+			m.age = cache.Age().Seconds()
+		}
+		if !m.stats.LastSuccess.IsZero() {
+			m.lastSuccess = float64(m.stats.LastSuccess.UnixNano()) / 1e9
+		}
+		caches = append(caches, m)
+	}
+	slices.SortFunc(caches, func(a, b cacheMetrics) int {
+		return cmp.Compare(a.name, b.name)
+	})
+
+	bw := bufio.NewWriter(w)
+	writeMetric(bw, "recached_update_total", "counter", "Number of calls of the update function.", caches,
+		func(m cacheMetrics) (float64, bool) { return float64(m.stats.Updates), true })
+	writeMetric(bw, "recached_failure_total", "counter", "Number of failed calls of the update function.", caches,
+		func(m cacheMetrics) (float64, bool) { return float64(m.stats.Failures), true })
+	writeMetric(bw, "recached_value_age_seconds", "gauge", "Time since the last successful update of the value.", caches,
+		func(m cacheMetrics) (float64, bool) { return m.age, m.updated })
+	writeMetric(bw, "recached_last_success_timestamp", "gauge", "Unix time of the last successful call of the update function.", caches,
+		func(m cacheMetrics) (float64, bool) { return m.lastSuccess, true })
+	return bw.Flush()
+}
+
+// ServeHTTP writes the metrics as the response
+func (c *Collector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = c.Collect(w)
+}
+
+// writeMetric writes a metric family with a sample for every cache value reports ok for
+func writeMetric(w io.Writer, name, kind, help string, caches []cacheMetrics, value func(cacheMetrics) (float64, bool)) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	for _, m := range caches {
+		if v, ok := value(m); ok {
+			fmt.Fprintf(w, "%s{cache=\"%s\"} %g\n", name, labelEscaper.Replace(m.name), v)
+		}
+	}
+}
+
+// labelEscaper escapes label values as required by the text exposition format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Metrics receives the metrics of every update of a cache, see WithMetrics.
+// It lets any metrics library be plugged in, e.g. StatsD or OpenTelemetry, while Collector
+// serves the Prometheus format without one. name is the name of the cache, empty if it is unnamed
+type Metrics interface {
+	// IncUpdates counts a call of the update function, like Stats.Updates
+	IncUpdates(name string)
+	// IncFailures counts a failed call of the update function, like Stats.Failures
+	IncFailures(name string)
+	// ObserveDuration records the duration of a call of the update function
+	ObserveDuration(name string, d time.Duration)
+	// SetAge sets the age of the value after the update, it is not called while the cache has no value
+	SetAge(name string, age time.Duration)
+}
+
+// reportMetrics reports an update to the metrics set by WithMetrics.
+// It is called without the lock, so the metrics may use the cache, e.g. its Stats
+func (r *reCached[T]) reportMetrics(err error, d time.Duration) {
+	r.metrics.IncUpdates(r.name)
+	if err != nil {
+		r.metrics.IncFailures(r.name)
+	}
+	r.metrics.ObserveDuration(r.name, d)
+	if updated := r.LastUpdated(); !updated.IsZero() {
+		r.metrics.SetAge(r.name, r.clock.Now().Sub(updated))
+	}
+}
@@ -0,0 +1,145 @@
+package recached
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCollector(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	ok := New(ctx, time.Hour, func() (int, error) {
+		return 1, nil
+	}, WithName[int](`ok "1"`), WithClock[int](clock))
+	defer ok.Close()
+	failing := New(ctx, time.Hour, func() (int, error) {
+		return 0, errors.New("update failed")
+	}, WithName[int]("failing"), WithClock[int](clock))
+	defer failing.Close()
+	unnamed := New(ctx, time.Hour, func() (int, error) {
+		return 0, nil
+	})
+	defer unnamed.Close()
+
+	clock.Advance(90 * time.Second)
+
+	rec := httptest.NewRecorder()
+	NewCollector().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	got := rec.Body.String()
+
+	for _, want := range []string{
+		"# TYPE recached_update_total counter\n",
+		`recached_update_total{cache="failing"} 1` + "\n",
+		`recached_update_total{cache="ok \"1\""} 1` + "\n",
+		`recached_failure_total{cache="failing"} 1` + "\n",
+		`recached_failure_total{cache="ok \"1\""} 0` + "\n",
+		`recached_value_age_seconds{cache="ok \"1\""} 90` + "\n",
+		`recached_last_success_timestamp{cache="failing"} 0` + "\n",
+		`recached_last_success_timestamp{cache="ok \"1\""} 1.7040672e+09` + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Collector output does not contain %q:\n%s", want, got)
+		}
+	}
+
+	// A cache without a value has no age, unnamed caches are skipped
+	if strings.Contains(got, `recached_value_age_seconds{cache="failing"}`) {
+		t.Errorf("Collector output has an age for a cache without a value:\n%s", got)
+	}
+	if strings.Contains(got, `cache=""`) {
+		t.Errorf("Collector output has an unnamed cache:\n%s", got)
+	}
+}
+
+// testMetrics records the calls of Metrics as strings
+type testMetrics struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (m *testMetrics) record(format string, args ...any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, fmt.Sprintf(format, args...))
+}
+
+func (m *testMetrics) IncUpdates(name string) {
+	m.record("updates %s", name)
+}
+
+func (m *testMetrics) IncFailures(name string) {
+	m.record("failures %s", name)
+}
+
+func (m *testMetrics) ObserveDuration(name string, _ time.Duration) {
+	m.record("duration %s", name)
+}
+
+func (m *testMetrics) SetAge(name string, age time.Duration) {
+	m.record("age %s %v", name, age)
+}
+
+func (m *testMetrics) take() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	calls := m.calls
+	m.calls = nil
+	return calls
+}
+
+func TestWithMetrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	metrics := &testMetrics{}
+	var fail bool
+	cache := New(ctx, time.Hour, func() (int, error) {
+		if fail {
+			return 0, errors.New("source is down")
+		}
+		return 1, nil
+	}, WithClock[int](clock), WithMetrics[int](metrics), WithName[int]("test-metrics"), WithoutGlobalRegistration[int]())
+	defer cache.Close()
+	<-clock.created
+
+	if got, want := metrics.take(), []string{"updates test-metrics", "duration test-metrics", "age test-metrics 0s"}; !slices.Equal(got, want) {
+		t.Errorf("Metrics of the initial update = %q, want %q", got, want)
+	}
+
+	// A failure is counted and the value ages
+	fail = true
+	clock.Advance(5 * time.Second)
+	cache.Update()
+	want := []string{"updates test-metrics", "failures test-metrics", "duration test-metrics", "age test-metrics 5s"}
+	if got := metrics.take(); !slices.Equal(got, want) {
+		t.Errorf("Metrics of a failed update = %q, want %q", got, want)
+	}
+}
+
+func TestWithMetricsWithoutValue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// No age is reported while there is no value
+	metrics := &testMetrics{}
+	cache := New(ctx, time.Hour, func() (int, error) {
+		return 0, errors.New("source is down")
+	}, WithMetrics[int](metrics), WithoutGlobalRegistration[int]())
+	defer cache.Close()
+
+	if got, want := metrics.take(), []string{"updates ", "failures ", "duration "}; !slices.Equal(got, want) {
+		t.Errorf("Metrics of a failed initial update = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,377 @@
+package recached
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Option configures a cache created by New, NewOrError, NewNamed or NewCtx.
+// Options are applied in order, so a later option overrides an earlier one setting the same knob.
+// Options that do not take a value of T need the type argument spelled out, e.g. WithJitter[int](0.2)
+type Option[T any] func(*reCached[T])
+
+// WithTimeout limits every call of the update function to d.
+// Only has effect for update functions accepting a context, see NewCtx
+func WithTimeout[T any](d time.Duration) Option[T] {
+	return func(r *reCached[T]) {
+		r.timeout = d
+	}
+}
+
+// WithInitialTimeout limits the calls of the update function by the initial update of the constructor
+// to d instead of the timeout set by WithTimeout, e.g. to allow a slow first load at startup.
+// With WithInitialRetry every attempt gets d. Only has effect for update functions accepting a context
+func WithInitialTimeout[T any](d time.Duration) Option[T] {
+	return func(r *reCached[T]) {
+		r.initTime = d
+	}
+}
+
+// WithJitter randomizes every interval between automatic updates by up to ±fraction of the period,
+// e.g. 0.2 makes each interval period×[0.8, 1.2]. A fraction of 0 keeps the exact period.
+// The fraction is clamped to [0, 1], NaN is treated as 0
+func WithJitter[T any](fraction float64) Option[T] {
+	return func(r *reCached[T]) {
+		if math.IsNaN(fraction) {
+			fraction = 0
+		}
+		r.jitter = min(max(fraction, 0), 1)
+	}
+}
+
+// WithBackoff makes the update loop wait longer after consecutive failures:
+// period, 2×period, 4×period and so on, capped at max. The first success resets the interval to period.
+// Explicit Update calls are not affected
+func WithBackoff[T any](max time.Duration) Option[T] {
+	return func(r *reCached[T]) {
+		r.maxBackoff = max
+	}
+}
+
+// WithFallback sets sources tried in order when the update function fails. The first one that succeeds
+// provides the value, see Stats.Source. Only if all of them fail the update fails, with the joined errors
+func WithFallback[T any](fallbacks ...func() (T, error)) Option[T] {
+	return func(r *reCached[T]) {
+		for _, fallback := range fallbacks {
+			r.fallbacks = append(r.fallbacks, ignoreContext(fallback))
+		}
+	}
+}
+
+// WithCircuitBreaker stops the update loop from calling the update function for cooldown
+// once failureThreshold updates in a row have failed. After the cooldown the next automatic update
+// probes the source: a success closes the breaker, a failure keeps it open for another cooldown.
+// Get keeps returning the last value meanwhile. Explicit updates bypass the breaker,
+// but their results count. The state is reported by Stats
+func WithCircuitBreaker[T any](failureThreshold int, cooldown time.Duration) Option[T] {
+	return func(r *reCached[T]) {
+		r.breaker = &breaker{threshold: max(failureThreshold, 1), cooldown: cooldown}
+	}
+}
+
+// WithReject makes updates returning a value fn reports true for fail with ErrRejected,
+// e.g. because the source returns an empty result on partial failure. The previous value is kept
+// and a fallback, if any, is tried next, see WithFallback
+func WithReject[T any](fn func(T) bool) Option[T] {
+	return func(r *reCached[T]) {
+		r.reject = fn
+	}
+}
+
+// WithRejectZero is WithReject rejecting the zero value
+func WithRejectZero[T comparable]() Option[T] {
+	return WithReject(func(value T) bool {
+		var zero T
+		return value == zero
+	})
+}
+
+// WithErrorHandler sets a handler choosing what happens to the value when an update fails,
+// see ErrorAction. Without a handler the value is kept
+func WithErrorHandler[T any](handler func(err error) ErrorAction) Option[T] {
+	return func(r *reCached[T]) {
+		r.onError = handler
+	}
+}
+
+// WithOnUpdate sets a callback called after every successful update with the previous and the new value
+func WithOnUpdate[T any](fn func(old, new T)) Option[T] {
+	return func(r *reCached[T]) {
+		r.onUpdate = fn
+	}
+}
+
+// WithSlowThreshold sets a callback called with the duration of every call of the update function
+// taking longer than d, whether it succeeds or not, e.g. to warn about a degrading source.
+// Like the hooks of WithOnUpdateEnd, it runs within the update and must not update the cache itself
+func WithSlowThreshold[T any](d time.Duration, onSlow func(d time.Duration)) Option[T] {
+	return func(r *reCached[T]) {
+		r.slowAfter = d
+		r.onSlow = onSlow
+	}
+}
+
+// WithOnUpdateStart sets a hook called before every call of the update function. The context it returns
+// is passed to the update function, e.g. carrying a tracing span started by the hook, see NewCtx.
+// The context given to the hook is derived from the context passed to the constructor
+func WithOnUpdateStart[T any](fn func(ctx context.Context) context.Context) Option[T] {
+	return func(r *reCached[T]) {
+		r.onStart = fn
+	}
+}
+
+// WithOnUpdateEnd sets a hook called after every call of the update function with the context returned
+// by the hook set by WithOnUpdateStart and the error of the call, e.g. to end a tracing span
+func WithOnUpdateEnd[T any](fn func(ctx context.Context, err error)) Option[T] {
+	return func(r *reCached[T]) {
+		r.onEnd = fn
+	}
+}
+
+// WithEqual sets a function to compare values. An update returning a value equal to the cached one
+// keeps the cached value and its timestamp and does not fire the OnUpdate callback
+func WithEqual[T any](fn func(a, b T) bool) Option[T] {
+	return func(r *reCached[T]) {
+		r.equal = fn
+	}
+}
+
+// WithCopy sets a function returning a copy of a value. Get, GetWithError and GetFresh return
+// a copy made by it, so callers may modify values of slice or map types without affecting the cache
+// or each other. The cached value itself is stored as returned by the update function
+func WithCopy[T any](fn func(T) T) Option[T] {
+	return func(r *reCached[T]) {
+		r.copy = fn
+	}
+}
+
+// WithInitialRetry makes the constructor try the initial update up to attempts times,
+// waiting delay between the attempts, before giving up on it. It stops early if the context is done.
+// The update loop and explicit updates are not retried
+func WithInitialRetry[T any](attempts int, delay time.Duration) Option[T] {
+	return func(r *reCached[T]) {
+		r.retries = attempts
+		r.retryDelay = delay
+	}
+}
+
+// WithUpdateRetry retries failed calls of the update function within every update, automatic or
+// explicit, as set by policy, so e.g. ForceUpdate either succeeds eventually or returns the last error.
+// The retries stop when the context of the update is done, see WithTimeout. Stats count every update once
+func WithUpdateRetry[T any](policy RetryPolicy) Option[T] {
+	return func(r *reCached[T]) {
+		r.retry = policy
+	}
+}
+
+// WithMinInterval drops explicit updates, e.g. by Update, ForceUpdate or GlobalCacheUpdate,
+// called less than d after the last successful update. ForceUpdate returns ErrThrottled for them
+// and Stats counts them. Automatic updates of the update loop are not affected
+func WithMinInterval[T any](d time.Duration) Option[T] {
+	return func(r *reCached[T]) {
+		r.throttle = d
+	}
+}
+
+// WithLazy skips the initial update in the constructor. The value is loaded on the first Get,
+// which blocks until the load finishes, and is kept up to date by the update loop afterwards.
+// If the first load fails, Get returns the zero value until the update loop or an explicit update succeeds
+func WithLazy[T any]() Option[T] {
+	return func(r *reCached[T]) {
+		r.lazy = true
+	}
+}
+
+// WithLazyNoWait is like WithLazy, but Get does not wait for the first load:
+// it starts the load in the background and returns the zero value until the load succeeds
+func WithLazyNoWait[T any]() Option[T] {
+	return func(r *reCached[T]) {
+		r.lazy = true
+		r.lazyNoWait = true
+	}
+}
+
+// WithImmediateTick makes the update loop update the cache as soon as it starts instead of
+// waiting for the first period, so a cache created with WithLazy is loaded in the background
+// right away. With the initial update of the constructor it only makes a difference if that update
+// failed, a value it loaded is not loaded again
+func WithImmediateTick[T any]() Option[T] {
+	return func(r *reCached[T]) {
+		r.immediate = true
+	}
+}
+
+// WithTryLock makes automatic updates drop their new value instead of waiting for the lock guarding
+// the value while readers hold it, counting them in Stats().SkippedTicks, so a hot read path does not
+// pile up behind a refresh. The value is then refreshed by the next tick. Failures are recorded as usual.
+// Explicit updates wait as usual, also when they join an automatic update that dropped its value
+func WithTryLock[T any]() Option[T] {
+	return func(r *reCached[T]) {
+		r.tryLock = true
+	}
+}
+
+// WithReadThrough sets a function Get and GetWithError read the value from until the cache is ready,
+// e.g. a cheap but possibly outdated persistent store, so a slow initial load does not expose the zero value.
+// If it reports false, the cached value is returned as usual. GetWithError still returns ErrNotReady
+// with its value. Once an update has succeeded, the function is not called anymore
+func WithReadThrough[T any](through func() (T, bool)) Option[T] {
+	return func(r *reCached[T]) {
+		r.through = through
+	}
+}
+
+// WithAdaptivePeriod adapts the period of automatic updates to how often the value changes:
+// every update finding it unchanged, see WithEqual and NewConditional, doubles the period up to max,
+// and every change halves it down to min. It starts from the period of the constructor or SetPeriod
+// limited to [min, max], the current one is reported in Stats().Period. It has no effect if min
+// is not positive, max is below min or the cache has no automatic updates
+func WithAdaptivePeriod[T any](min, max time.Duration) Option[T] {
+	return func(r *reCached[T]) {
+		if min <= 0 || max < min {
+			return
+		}
+		r.minPeriod, r.maxPeriod = min, max
+		r.adaptive = r.period
+		if r.adaptive < min {
+			r.adaptive = min
+		}
+		if r.adaptive > max {
+			r.adaptive = max
+		}
+	}
+}
+
+// WithTrigger makes the update loop also update the cache whenever a value is received from ch,
+// e.g. on file changes or messages of an event stream, sharing an update that is already running.
+// The periodic updates go on as a safety net, each trigger starts a new interval. Like automatic
+// updates, triggered ones are skipped while paused or while the circuit breaker is open.
+// Closing ch stops the triggers
+func WithTrigger[T any](ch <-chan struct{}) Option[T] {
+	return func(r *reCached[T]) {
+		r.trigger = ch
+	}
+}
+
+// WithMaxStaleness makes GetWithError return ErrStale together with the value
+// once no update has succeeded for longer than d
+func WithMaxStaleness[T any](d time.Duration) Option[T] {
+	return func(r *reCached[T]) {
+		r.maxStaleness = d
+	}
+}
+
+// WithZeroOnStale makes Get return the zero value instead of a value that is stale, see WithMaxStaleness
+func WithZeroOnStale[T any]() Option[T] {
+	return func(r *reCached[T]) {
+		r.zeroOnStale = true
+	}
+}
+
+// WithTTL sets the time to live of the value, independent of the period of the update loop.
+// Once no update has succeeded for longer than d, the value has expired: Get returns the zero value
+// and GetWithError returns it together with ErrExpired. Unlike WithMaxStaleness, which keeps
+// serving the old value, an expired value is never returned
+func WithTTL[T any](d time.Duration) Option[T] {
+	return func(r *reCached[T]) {
+		r.ttl = d
+	}
+}
+
+// WithRefreshAhead makes the update loop refresh the value lead before it would become stale
+// or expire, see WithMaxStaleness and WithTTL, instead of waiting for the next period.
+// E.g. a lead of 20% of the TTL refreshes once 80% of it has passed, so Get keeps returning
+// fresh values even when updates are slow. If that refresh fails, the loop falls back to the period.
+// Has no effect without WithMaxStaleness or WithTTL
+func WithRefreshAhead[T any](lead time.Duration) Option[T] {
+	return func(r *reCached[T]) {
+		r.refreshAhead = lead
+	}
+}
+
+// WithName sets the name the cache is registered under in the global registry, see LookupCache
+func WithName[T any](name string) Option[T] {
+	return func(r *reCached[T]) {
+		r.name = name
+	}
+}
+
+// WithTags attaches tags to the cache, so a group of caches can be refreshed with RefreshTag
+func WithTags[T any](tags ...string) Option[T] {
+	return func(r *reCached[T]) {
+		r.tags = append(r.tags, tags...)
+	}
+}
+
+// WithSingleton is like WithName, but constructing a cache with a name that is already registered
+// returns the registered cache instead of creating a second one or panicking. The arguments of the
+// constructor and the other options are then ignored. Constructing it with a different value type fails
+func WithSingleton[T any](name string) Option[T] {
+	return func(r *reCached[T]) {
+		r.name = name
+		r.singleton = true
+	}
+}
+
+// WithoutGlobalRegistration keeps the cache out of the global registry. Such a cache
+// runs its own update loop as usual, but it is not refreshed by GlobalCacheUpdate and friends,
+// cannot be found by LookupCache and does not reserve its name. The tradeoff is that
+// the owner is the only one able to refresh it, so it suits caches private to a library
+func WithoutGlobalRegistration[T any]() Option[T] {
+	return func(r *reCached[T]) {
+		r.registered = false
+	}
+}
+
+// WithBroadcaster keeps the caches with the same name on several instances of a service in sync:
+// a successful explicit update or Invalidate publishes the name of the cache to b, and a published name
+// invalidates the local cache, see Invalidate. An instance receives its own publications as well,
+// which costs one extra update. Has no effect for unnamed caches, see WithName
+func WithBroadcaster[T any](b Broadcaster) Option[T] {
+	return func(r *reCached[T]) {
+		r.broadcast = b
+	}
+}
+
+// WithClock replaces the real time used for timestamps, Age, staleness and the update loop,
+// mainly to drive a cache deterministically in tests
+func WithClock[T any](c Clock) Option[T] {
+	return func(r *reCached[T]) {
+		r.clock = c
+	}
+}
+
+// WithLogger sets a logger receiving the outcome and duration of every update.
+// It overrides the logger set by SetDefaultLogger, a nil logger disables logging, which is the default
+func WithLogger[T any](l Logger) Option[T] {
+	return func(r *reCached[T]) {
+		r.logger = l
+	}
+}
+
+// WithMetrics sets the metrics receiving the outcome and duration of every update and the age of the value.
+// A nil value disables them, which is the default
+func WithMetrics[T any](m Metrics) Option[T] {
+	return func(r *reCached[T]) {
+		r.metrics = m
+	}
+}
+
+// WithCodec sets the codec encoding the value for GlobalSnapshot and GlobalRestore.
+// Without it, the codec of WithPersistence is used, if any
+func WithCodec[T any](codec Codec[T]) Option[T] {
+	return func(r *reCached[T]) {
+		r.codec = codec
+	}
+}
+
+// WithPersistence stores the value in the file at path after every successful update,
+// encoded with codec, and loads it on construction before the initial update,
+// so the cache has a value to serve even if the source is unavailable at startup.
+// Load and save errors are reported to the logger
+func WithPersistence[T any](path string, codec Codec[T]) Option[T] {
+	return func(r *reCached[T]) {
+		r.file = &persistence[T]{path: path, codec: codec}
+	}
+}
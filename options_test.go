@@ -0,0 +1,32 @@
+package recached
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOptionsCompose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updateFunc := func() (int, error) {
+		return 1, nil
+	}
+
+	// Without options the cache is configured as before options existed
+	plain := newReCached(ctx, time.Second, ignoreContext(updateFunc))
+	if plain.jitter != 0 || plain.maxBackoff != 0 || plain.timeout != 0 || plain.lazy || plain.logger != nil {
+		t.Errorf("newReCached() without options is not the default configuration")
+	}
+
+	// Options compose and later ones win
+	r := newReCached(ctx, time.Second, ignoreContext(updateFunc),
+		WithJitter[int](0.1),
+		WithTimeout[int](time.Millisecond),
+		WithJitter[int](0.3),
+	)
+	if r.jitter != 0.3 || r.timeout != time.Millisecond {
+		t.Errorf("Composed options = (jitter %v, timeout %v), want (%v, %v)", r.jitter, r.timeout, 0.3, time.Millisecond)
+	}
+}
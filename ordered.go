@@ -0,0 +1,72 @@
+package recached
+
+import (
+	"cmp"
+	"context"
+	"time"
+)
+
+// ReCachedOrdered is a cache of ordered values that also tracks the extremes of all values it held
+type ReCachedOrdered[T cmp.Ordered] interface {
+	ReCached[T]
+	// MinSeen returns the smallest value the cache held since it was created, including values
+	// passed to Set and values replaced since. It is the zero value until the cache has a value
+	MinSeen() T
+	// MaxSeen is like MinSeen, but returns the largest value
+	MaxSeen() T
+}
+
+type reCachedOrdered[T cmp.Ordered] struct {
+	*reCached[T]
+}
+
+// NewOrdered is like New, but the cache also tracks the smallest and the largest value it held,
+// e.g. the session low and high of a price, see ReCachedOrdered
+func NewOrdered[T cmp.Ordered](ctx context.Context, period time.Duration, updateFunc func() (T, error), opts ...Option[T]) ReCachedOrdered[T] {
+	cache := newReCached(ctx, period, ignoreContext(updateFunc), opts...)
+	cache.seen = &extremes[T]{less: cmp.Less[T]}
+
+	started, err := cache.run(false)
+	if err != nil {
+		panic(err)
+	}
+	return reCachedOrdered[T]{started}
+}
+
+func (r reCachedOrdered[T]) MinSeen() T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.seen == nil {
+		var zero T
+		return zero
+	}
+	return r.seen.min
+}
+
+func (r reCachedOrdered[T]) MaxSeen() T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.seen == nil {
+		var zero T
+		return zero
+	}
+	return r.seen.max
+}
+
+// extremes tracks the smallest and the largest value stored in a cache, see NewOrdered
+type extremes[T any] struct {
+	less     func(a, b T) bool
+	min, max T
+	ok       bool
+}
+
+// observe records a value stored in the cache
+func (e *extremes[T]) observe(value T) {
+	if !e.ok || e.less(value, e.min) {
+		e.min = value
+	}
+	if !e.ok || e.less(e.max, value) {
+		e.max = value
+	}
+	e.ok = true
+}
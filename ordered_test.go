@@ -0,0 +1,60 @@
+package recached
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewOrdered(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	prices := []float64{10, 12, 7, 9}
+	var fail bool
+	updateFunc := func() (float64, error) {
+		if fail {
+			return 0, errors.New("update failed")
+		}
+		price := prices[0]
+		prices = prices[1:]
+		return price, nil
+	}
+
+	cache := NewOrdered(ctx, time.Hour, updateFunc)
+	defer cache.Close()
+
+	for range 3 {
+		cache.Update()
+	}
+	if got := cache.Get(); got != 9 {
+		t.Errorf("Get() = %v, want %v", got, 9)
+	}
+	if got := cache.MinSeen(); got != 7 {
+		t.Errorf("MinSeen() = %v, want %v", got, 7)
+	}
+	if got := cache.MaxSeen(); got != 12 {
+		t.Errorf("MaxSeen() = %v, want %v", got, 12)
+	}
+
+	// Failed updates are not seen, values passed to Set are
+	fail = true
+	cache.Update()
+	cache.Set(15)
+	if got := cache.MinSeen(); got != 7 {
+		t.Errorf("MinSeen() after Set() = %v, want %v", got, 7)
+	}
+	if got := cache.MaxSeen(); got != 15 {
+		t.Errorf("MaxSeen() after Set() = %v, want %v", got, 15)
+	}
+
+	// Without a value there is nothing seen yet
+	empty := NewOrdered(ctx, time.Hour, func() (string, error) {
+		return "", errors.New("update failed")
+	})
+	defer empty.Close()
+	if got := empty.MinSeen(); got != "" {
+		t.Errorf("MinSeen() without a value = %q, want the zero value", got)
+	}
+}
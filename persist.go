@@ -0,0 +1,106 @@
+package recached
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Codec converts cached values to bytes and back
+type Codec[T any] interface {
+	Marshal(value T) ([]byte, error)
+	Unmarshal(data []byte) (T, error)
+}
+
+// JSONCodec encodes values with encoding/json
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Marshal(value T) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (JSONCodec[T]) Unmarshal(data []byte) (T, error) {
+	var value T
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+// GobCodec encodes values with encoding/gob
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Marshal(value T) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(value)
+	return buf.Bytes(), err
+}
+
+func (GobCodec[T]) Unmarshal(data []byte) (T, error) {
+	var value T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, err
+}
+
+// persistence stores the cached value in a file
+type persistence[T any] struct {
+	mu    sync.Mutex
+	path  string
+	codec Codec[T]
+}
+
+// load reads the stored value. It reports false without an error if there is no stored value yet
+func (p *persistence[T]) load() (T, os.FileInfo, bool, error) {
+	var zero T
+
+	data, err := os.ReadFile(p.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return zero, nil, false, nil
+	}
+	if err != nil {
+		return zero, nil, false, err
+	}
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return zero, nil, false, err
+	}
+
+	value, err := p.codec.Unmarshal(data)
+	if err != nil {
+		return zero, nil, false, err
+	}
+	return value, info, true, nil
+}
+
+// save stores value, writing to a temporary file first so a partial write never replaces the stored value
+func (p *persistence[T]) save(value T) error {
+	data, err := p.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(p.path), filepath.Base(p.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p.path)
+}
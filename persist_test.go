@@ -0,0 +1,60 @@
+package recached
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWithPersistence(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "cache.json")
+	type config struct {
+		Name  string
+		Limit int
+	}
+
+	// A successful update stores the value
+	cache := New(ctx, time.Hour, func() (config, error) {
+		return config{Name: "saved", Limit: 3}, nil
+	}, WithPersistence[config](path, JSONCodec[config]{}), WithoutGlobalRegistration[config]())
+	cache.Close()
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Persisted file is missing: %v", err)
+	}
+
+	// The stored value is served when the initial update of a new cache fails
+	restored := New(ctx, time.Hour, func() (config, error) {
+		return config{}, errors.New("source is down")
+	}, WithPersistence[config](path, JSONCodec[config]{}), WithoutGlobalRegistration[config]())
+	defer restored.Close()
+
+	if got := restored.Get(); got != (config{Name: "saved", Limit: 3}) {
+		t.Errorf("Get() after restoring = %+v, want the persisted value", got)
+	}
+	if restored.Ready() {
+		t.Errorf("Ready() after restoring = true, want false until an update succeeds")
+	}
+}
+
+func TestGobCodec(t *testing.T) {
+	codec := GobCodec[map[string]int]{}
+
+	data, err := codec.Marshal(map[string]int{"a": 1})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	value, err := codec.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if value["a"] != 1 {
+		t.Errorf("Unmarshal() = %v, want map[a:1]", value)
+	}
+}
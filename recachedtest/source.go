@@ -0,0 +1,75 @@
+// Package recachedtest provides helpers for testing code using recached
+package recachedtest
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrInjected is the error returned by a Source after FailOnce
+var ErrInjected = errors.New("recachedtest: injected failure")
+
+// Source is an in-memory data source for a cache, see Func.
+// The zero value is a source returning the zero value. It is safe for concurrent use
+type Source[T any] struct {
+	mu       sync.Mutex
+	value    T
+	err      error
+	failOnce bool
+	calls    int
+}
+
+// NewSource returns a source returning value
+func NewSource[T any](value T) *Source[T] {
+	return &Source[T]{value: value}
+}
+
+// Func returns the update function to pass to recached.New
+func (s *Source[T]) Func() func() (T, error) {
+	return s.load
+}
+
+func (s *Source[T]) load() (T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	if s.failOnce {
+		s.failOnce = false
+		var zero T
+		return zero, ErrInjected
+	}
+	if s.err != nil {
+		var zero T
+		return zero, s.err
+	}
+	return s.value, nil
+}
+
+// SetValue sets the value returned by following calls
+func (s *Source[T]) SetValue(value T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.value = value
+}
+
+// SetError makes following calls fail with err until it is set to nil again
+func (s *Source[T]) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// FailOnce makes the next call fail with ErrInjected
+func (s *Source[T]) FailOnce() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failOnce = true
+}
+
+// Calls returns the number of calls of the update function
+func (s *Source[T]) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
@@ -0,0 +1,55 @@
+package recachedtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/petar/recached"
+)
+
+func TestSource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := NewSource("initial")
+	cache := recached.New(ctx, time.Hour, source.Func(), recached.WithoutGlobalRegistration[string]())
+	defer cache.Close()
+
+	if got := cache.Get(); got != "initial" {
+		t.Errorf("Get() = %q, want %q", got, "initial")
+	}
+
+	source.SetValue("changed")
+	cache.Update()
+	if got := cache.Get(); got != "changed" {
+		t.Errorf("Get() after SetValue() = %q, want %q", got, "changed")
+	}
+
+	// FailOnce fails the next call only
+	source.FailOnce()
+	if err := cache.ForceUpdate(); !errors.Is(err, ErrInjected) {
+		t.Errorf("ForceUpdate() after FailOnce() = %v, want %v", err, ErrInjected)
+	}
+	if err := cache.ForceUpdate(); err != nil {
+		t.Errorf("ForceUpdate() after the injected failure = %v, want nil", err)
+	}
+
+	// SetError fails until it is cleared
+	sourceErr := errors.New("source is down")
+	source.SetError(sourceErr)
+	for range 2 {
+		if err := cache.ForceUpdate(); !errors.Is(err, sourceErr) {
+			t.Errorf("ForceUpdate() after SetError() = %v, want %v", err, sourceErr)
+		}
+	}
+	source.SetError(nil)
+	if err := cache.ForceUpdate(); err != nil {
+		t.Errorf("ForceUpdate() after clearing the error = %v, want nil", err)
+	}
+
+	if got := source.Calls(); got != 7 {
+		t.Errorf("Calls() = %v, want %v", got, 7)
+	}
+}
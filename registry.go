@@ -0,0 +1,387 @@
+package recached
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// registered is a cache instance kept in the global registry
+type registered interface {
+	fmt.Stringer
+	Update()
+	ForceUpdate() error
+	forceUpdate(ctx context.Context) error
+	Close()
+	Stats() Stats
+	LastUpdated() time.Time
+	Age() time.Duration
+	Version() uint64
+	Ready() bool
+	cacheName() string
+	cacheTags() []string
+	inScope(s *scope) bool
+	done() bool
+	snapshot() ([]byte, bool, error)
+	restore(data []byte) (bool, error)
+}
+
+// Global registry to keep track of all cache instances
+var (
+	globalCachesMutex sync.RWMutex
+	globalCaches      = make(map[registered]struct{})
+	globalCacheNames  = make(map[string]registered)
+
+	// singletonMu serializes the construction of caches with WithSingleton
+	singletonMu sync.Mutex
+)
+
+// checkName fails if a cache with the given name is already registered
+func checkName(name string) error {
+	globalCachesMutex.Lock()
+	defer globalCachesMutex.Unlock()
+	return checkNameLocked(name)
+}
+
+// checkNameLocked is checkName for callers holding globalCachesMutex for writing.
+// A cache whose context is done frees its name right away, its loop may not have deregistered it yet
+func checkNameLocked(name string) error {
+	if c, ok := globalCacheNames[name]; name != "" && ok {
+		if !c.done() {
+			return fmt.Errorf("recached: cache %q is already registered", name)
+		}
+		delete(globalCacheNames, name)
+		delete(globalCaches, c)
+	}
+	return nil
+}
+
+// register adds a cache to the global registry.
+// It fails if the cache is named and the name is already taken
+func register(c registered) error {
+	globalCachesMutex.Lock()
+	defer globalCachesMutex.Unlock()
+
+	name := c.cacheName()
+	if err := checkNameLocked(name); err != nil {
+		return err
+	}
+	if name != "" {
+		globalCacheNames[name] = c
+	}
+	globalCaches[c] = struct{}{}
+	return nil
+}
+
+// deregister removes a cache from the global registry
+func deregister(c registered) {
+	globalCachesMutex.Lock()
+	defer globalCachesMutex.Unlock()
+
+	if name := c.cacheName(); name != "" && globalCacheNames[name] == c {
+		delete(globalCacheNames, name)
+	}
+	delete(globalCaches, c)
+}
+
+// LookupCache returns the registered cache with the given name
+func LookupCache(name string) (interface{ Update() }, bool) {
+	return lookup(name)
+}
+
+// lookup returns the registered cache with the given name.
+// A cache whose context is done is deregistered instead, like by checkNameLocked
+func lookup(name string) (registered, bool) {
+	globalCachesMutex.Lock()
+	defer globalCachesMutex.Unlock()
+
+	if checkNameLocked(name) == nil {
+		return nil, false
+	}
+	return globalCacheNames[name], true
+}
+
+// CacheInfo describes a registered cache, see ListCaches
+type CacheInfo struct {
+	// Name is the name of the cache, empty if it is unnamed, see WithName
+	Name string
+	// Tags are the tags of the cache, see WithTags
+	Tags []string
+	// Ready reports whether at least one update has succeeded
+	Ready bool
+	// Age is the time passed since the last successful update, zero if the cache has no value
+	Age time.Duration
+	// Version is the version of the value, see ReCached.Version
+	Version uint64
+	// LastError is the error of the last update, nil if it succeeded
+	LastError error
+	// Stats are the update statistics of the cache, see ReCached.Stats
+	Stats Stats
+}
+
+// ListCaches describes all registered caches, sorted by name
+func ListCaches() []CacheInfo {
+	caches := registeredCaches()
+	infos := make([]CacheInfo, 0, len(caches))
+	for _, cache := range caches {
+		stats := cache.Stats()
+		info := CacheInfo{
+			Name:      cache.cacheName(),
+			Tags:      slices.Clone(cache.cacheTags()),
+			Ready:     cache.Ready(),
+			Version:   cache.Version(),
+			LastError: stats.LastError,
+			Stats:     stats,
+		}
+		if !cache.LastUpdated().IsZero() {
+			info.Age = cache.Age()
+		}
+		infos = append(infos, info)
+	}
+	slices.SortStableFunc(infos, func(a, b CacheInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return infos
+}
+
+// CacheCount returns the number of registered caches
+func CacheCount() int {
+	globalCachesMutex.RLock()
+	defer globalCachesMutex.RUnlock()
+	return len(globalCaches)
+}
+
+// registeredCaches returns a snapshot of the global registry
+func registeredCaches() []registered {
+	globalCachesMutex.RLock()
+	defer globalCachesMutex.RUnlock()
+
+	caches := make([]registered, 0, len(globalCaches))
+	for cache := range globalCaches {
+		caches = append(caches, cache)
+	}
+	return caches
+}
+
+// TrimRegistry removes the registered caches whose context is done and returns how many it removed.
+// The update loop of a cache deregisters it once its context is done, so this only matters
+// for caches whose loop has not noticed yet. Global updates skip such caches anyway
+func TrimRegistry() int {
+	globalCachesMutex.Lock()
+	defer globalCachesMutex.Unlock()
+
+	trimmed := 0
+	for cache := range globalCaches {
+		if !cache.done() {
+			continue
+		}
+		if name := cache.cacheName(); name != "" && globalCacheNames[name] == cache {
+			delete(globalCacheNames, name)
+		}
+		delete(globalCaches, cache)
+		trimmed++
+	}
+	return trimmed
+}
+
+// GlobalCacheClose closes all registered caches and leaves the registry empty,
+// so a following GlobalCacheUpdate does nothing
+func GlobalCacheClose() {
+	for _, cache := range registeredCaches() {
+		cache.Close()
+	}
+
+	globalCachesMutex.Lock()
+	clear(globalCaches)
+	clear(globalCacheNames)
+	globalCachesMutex.Unlock()
+}
+
+// RefreshTag concurrently updates the registered caches carrying tag, see WithTags,
+// and returns the joined errors of the failed updates like GlobalCacheUpdateContext
+func RefreshTag(tag string) error {
+	return updateCaches(context.Background(), taggedCaches(tag), 0, 0)
+}
+
+// taggedCaches returns the registered caches carrying tag
+func taggedCaches(tag string) []registered {
+	var caches []registered
+	for _, cache := range registeredCaches() {
+		if slices.Contains(cache.cacheTags(), tag) {
+			caches = append(caches, cache)
+		}
+	}
+	return caches
+}
+
+// GlobalCacheUpdate updates all cache instances created via New
+func GlobalCacheUpdate() {
+	_ = GlobalCacheUpdateContext(context.Background())
+}
+
+// GlobalCacheUpdateContext updates all cache instances created via New concurrently
+// and returns the joined errors of the failed updates, each prefixed with the cache, see WithName.
+// Caches skipped because of WithMinInterval are not failures, their value was updated recently.
+// A cache already updating, e.g. by its update loop, is not updated again, its running update is waited for.
+// If ctx is done before all updates complete, it returns ctx.Err() without waiting for the rest
+func GlobalCacheUpdateContext(ctx context.Context) error {
+	return updateCaches(ctx, registeredCaches(), 0, 0)
+}
+
+// scope marks the caches created with a context returned by Scope
+type scope struct {
+	parent *scope
+}
+
+// scopeKey is the context key of the innermost scope
+type scopeKey struct{}
+
+// scopeOf returns the innermost scope of ctx, nil if there is none
+func scopeOf(ctx context.Context) *scope {
+	s, _ := ctx.Value(scopeKey{}).(*scope)
+	return s
+}
+
+// Scope returns a context derived from ctx marking the caches created with it, or with contexts
+// derived from it, so GlobalCacheUpdateFor can update just them, e.g. the caches of one tenant.
+// Scopes nest, the caches of an inner scope belong to the outer one as well
+func Scope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scopeKey{}, &scope{parent: scopeOf(ctx)})
+}
+
+// GlobalCacheUpdateFor is like GlobalCacheUpdateContext, but updates only the registered caches
+// created within the innermost scope of ctx, see Scope. Without a scope in ctx nothing is updated
+func GlobalCacheUpdateFor(ctx context.Context) error {
+	s := scopeOf(ctx)
+	if s == nil {
+		return nil
+	}
+
+	var caches []registered
+	for _, cache := range registeredCaches() {
+		if cache.inScope(s) {
+			caches = append(caches, cache)
+		}
+	}
+	return updateCaches(ctx, caches, 0, 0)
+}
+
+// GlobalCacheUpdateN is like GlobalCacheUpdate, but runs at most maxConcurrency updates at a time.
+// A maxConcurrency of 0 or less means no limit
+func GlobalCacheUpdateN(maxConcurrency int) {
+	_ = updateCaches(context.Background(), registeredCaches(), maxConcurrency, 0)
+}
+
+// GlobalCacheUpdateTimeout is like GlobalCacheUpdateContext, but limits the update of every cache
+// to perCache instead of sharing one deadline, so a hanging source does not hold up the others.
+// The context of an update function accepting one, see NewCtx, is cancelled after perCache.
+// A cache whose update times out keeps its old value and its error wraps context.DeadlineExceeded;
+// an update function ignoring its context may still store its value later.
+// A perCache of 0 or less means no limit
+func GlobalCacheUpdateTimeout(perCache time.Duration) error {
+	return updateCaches(context.Background(), registeredCaches(), 0, perCache)
+}
+
+// updateCaches updates caches concurrently, running at most limit updates at a time if limit is positive,
+// each limited to perCache if it is positive, see updateWithin.
+// caches is a snapshot and the registry is not locked while updating, so callbacks may create caches
+func updateCaches(ctx context.Context, caches []registered, limit int, perCache time.Duration) error {
+	// Create a wait group to update all caches concurrently
+	var wg sync.WaitGroup
+
+	var (
+		errsMu sync.Mutex
+		errs   []error
+	)
+
+	// Semaphore limiting the number of updates in flight.
+	// It is acquired before spawning, so there are never more than limit update goroutines
+	var sem chan struct{}
+	if limit > 0 {
+		sem = make(chan struct{}, limit)
+	}
+
+	// Update all caches concurrently
+	for _, cache := range caches {
+		// The context of the cache is done, but its loop has not deregistered it yet
+		if cache.done() {
+			deregister(cache)
+			continue
+		}
+
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		wg.Add(1)
+		go func(c registered) {
+			defer wg.Done()
+			if sem != nil {
+				defer func() { <-sem }()
+			}
+
+			// A cache closed after the snapshot was taken is not a failure, neither is one throttled
+			// by WithMinInterval, its value is recent. Errors name the failing cache, e.g. "recached[users]: ..."
+			if err := updateWithin(c, perCache); err != nil && !errors.Is(err, ErrClosed) && !errors.Is(err, ErrThrottled) {
+				errsMu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", c, err))
+				errsMu.Unlock()
+			}
+		}(cache)
+	}
+
+	// Wait for all updates to complete
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return errors.Join(errs...)
+}
+
+// updateWithin updates c, waiting at most perCache if it is positive.
+// The update runs in its own goroutine, so even an update function ignoring its context cannot hold up the caller
+func updateWithin(c registered, perCache time.Duration) error {
+	if perCache <= 0 {
+		return safeUpdate(context.Background(), c)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), perCache)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- safeUpdate(ctx, c)
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("update timed out after %v: %w", perCache, ctx.Err())
+	}
+}
+
+// safeUpdate updates c with ctx. Update functions are already protected, but callbacks may panic too
+func safeUpdate(ctx context.Context, c registered) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%w: %v", ErrPanic, p)
+		}
+	}()
+	return c.forceUpdate(ctx)
+}
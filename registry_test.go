@@ -0,0 +1,725 @@
+package recached
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// isolateRegistry gives the test an empty global registry, so global updates
+// do not touch caches left behind by other tests
+func isolateRegistry(t *testing.T) {
+	t.Helper()
+
+	globalCachesMutex.Lock()
+	caches, names := globalCaches, globalCacheNames
+	globalCaches = make(map[registered]struct{})
+	globalCacheNames = make(map[string]registered)
+	globalCachesMutex.Unlock()
+
+	t.Cleanup(func() {
+		globalCachesMutex.Lock()
+		globalCaches, globalCacheNames = caches, names
+		globalCachesMutex.Unlock()
+	})
+}
+
+func TestGlobalCacheUpdateContext(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Failed updates are reported with the name of the cache, successful ones are not
+	updateErr := errors.New("update failed")
+	failing := New(ctx, time.Hour, func() (int, error) {
+		return 0, updateErr
+	}, WithName[int]("failing"))
+	defer failing.Close()
+	working := New(ctx, time.Hour, func() (int, error) {
+		return 1, nil
+	}, WithName[int]("working"))
+	defer working.Close()
+
+	err := GlobalCacheUpdateContext(ctx)
+	if !errors.Is(err, updateErr) {
+		t.Errorf("GlobalCacheUpdateContext() = %v, want %v", err, updateErr)
+	}
+	if want := "recached[failing]: update failed"; err == nil || err.Error() != want {
+		t.Errorf("GlobalCacheUpdateContext() = %v, want %q", err, want)
+	}
+
+	// A hanging update does not block past the context
+	release := make(chan struct{})
+	defer close(release)
+	hanging := New(ctx, time.Hour, func() (int, error) {
+		<-release
+		return 0, nil
+	}, WithLazy[int]())
+	defer hanging.Close()
+
+	timeoutCtx, timeoutCancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer timeoutCancel()
+
+	start := time.Now()
+	if err := GlobalCacheUpdateContext(timeoutCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GlobalCacheUpdateContext() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("GlobalCacheUpdateContext() took %v, want it to return on context cancellation", elapsed)
+	}
+}
+
+func TestGlobalCacheUpdateTimeout(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The first update of every cache succeeds, the following ones of the hanging caches hang
+	var armed atomic.Bool
+	cancelled := make(chan struct{})
+	watching := NewCtx(ctx, time.Hour, func(ctx context.Context) (int, error) {
+		if !armed.Load() {
+			return 1, nil
+		}
+		<-ctx.Done()
+		close(cancelled)
+		return 0, ctx.Err()
+	}, WithName[int]("watching"))
+	defer watching.Close()
+
+	release := make(chan struct{})
+	defer close(release)
+	ignoring := New(ctx, time.Hour, func() (int, error) {
+		if !armed.Load() {
+			return 1, nil
+		}
+		<-release
+		return 2, nil
+	}, WithName[int]("ignoring"))
+	defer ignoring.Close()
+
+	var fast atomic.Int64
+	working := New(ctx, time.Hour, func() (int64, error) {
+		return fast.Add(1), nil
+	}, WithName[int64]("working"))
+	defer working.Close()
+
+	armed.Store(true)
+	start := time.Now()
+	err := GlobalCacheUpdateTimeout(20 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("GlobalCacheUpdateTimeout() took %v, want it to return after the per-cache timeout", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GlobalCacheUpdateTimeout() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	for _, name := range []string{"recached[watching]", "recached[ignoring]"} {
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("GlobalCacheUpdateTimeout() = %v, want it to report %s", err, name)
+		}
+	}
+	if err != nil && strings.Contains(err.Error(), "recached[working]") {
+		t.Errorf("GlobalCacheUpdateTimeout() = %v, want it not to report the working cache", err)
+	}
+
+	// The timed out caches keep their values, the others are updated
+	if got := watching.Get(); got != 1 {
+		t.Errorf("Get() of the cache timed out = %v, want %v", got, 1)
+	}
+	if got := ignoring.Get(); got != 1 {
+		t.Errorf("Get() of the cache ignoring its context = %v, want %v", got, 1)
+	}
+	if got := working.Get(); got != 2 {
+		t.Errorf("Get() of the working cache = %v, want %v", got, 2)
+	}
+
+	// The context of the update function is cancelled
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("Timed out waiting for the context of the update function to be cancelled")
+	}
+}
+
+func TestGlobalCacheUpdateN(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var inFlight, maxInFlight, calls int64
+	updateFunc := func() (int, error) {
+		n := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		for {
+			m := atomic.LoadInt64(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt64(&maxInFlight, m, n) {
+				break
+			}
+		}
+		atomic.AddInt64(&calls, 1)
+		time.Sleep(5 * time.Millisecond)
+		return 0, nil
+	}
+
+	for i := 0; i < 10; i++ {
+		cache := New(ctx, time.Hour, updateFunc, WithLazy[int]())
+		defer cache.Close()
+	}
+
+	GlobalCacheUpdateN(2)
+
+	if got := atomic.LoadInt64(&calls); got != 10 {
+		t.Errorf("updateFunc calls = %v, want %v", got, 10)
+	}
+	if got := atomic.LoadInt64(&maxInFlight); got > 2 {
+		t.Errorf("Max concurrent updates = %v, want at most %v", got, 2)
+	}
+}
+
+func TestGlobalCacheUpdateNGoroutines(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var maxGoroutines int64
+	updateFunc := func() (int, error) {
+		n := int64(runtime.NumGoroutine())
+		for {
+			m := atomic.LoadInt64(&maxGoroutines)
+			if n <= m || atomic.CompareAndSwapInt64(&maxGoroutines, m, n) {
+				break
+			}
+		}
+		return 0, nil
+	}
+
+	for i := 0; i < 200; i++ {
+		cache := New(ctx, time.Hour, updateFunc, WithLazy[int]())
+		defer cache.Close()
+	}
+
+	// Pending updates must not be spawned ahead of a free slot
+	before := int64(runtime.NumGoroutine())
+	GlobalCacheUpdateN(1)
+	if extra := atomic.LoadInt64(&maxGoroutines) - before; extra > 10 {
+		t.Errorf("GlobalCacheUpdateN(1) used %v extra goroutines, want only a few", extra)
+	}
+}
+
+func TestNewNamed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	value := 0
+	cache := NewNamed(ctx, "test-named", time.Hour, func() (int, error) {
+		value++
+		return value, nil
+	})
+
+	// The cache can be found and refreshed by name
+	found, ok := LookupCache("test-named")
+	if !ok {
+		t.Fatalf("LookupCache() did not find the named cache")
+	}
+	found.Update()
+	if got := cache.Get(); got != 2 {
+		t.Errorf("Get() after Update() via lookup = %v, want %v", got, 2)
+	}
+
+	// A duplicate name panics
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("NewNamed() with a duplicate name did not panic")
+			}
+		}()
+		_ = NewNamed(ctx, "test-named", time.Hour, func() (int, error) {
+			return 0, nil
+		})
+	}()
+
+	// NewOrError reports a duplicate name without calling updateFunc
+	called := false
+	dup, err := NewOrError(ctx, time.Hour, func() (int, error) {
+		called = true
+		return 0, nil
+	}, WithName[int]("test-named"))
+	if err == nil || dup != nil {
+		t.Errorf("NewOrError() with a duplicate name = (%v, %v), want an error", dup, err)
+	}
+	if called {
+		t.Errorf("NewOrError() with a duplicate name called updateFunc")
+	}
+
+	// A closed cache is no longer found and its name can be reused
+	cache.Close()
+	if _, ok := LookupCache("test-named"); ok {
+		t.Errorf("LookupCache() found a closed cache")
+	}
+	reused := NewNamed(ctx, "test-named", time.Hour, func() (int, error) {
+		return 0, nil
+	})
+	reused.Close()
+}
+
+func TestWithoutGlobalRegistration(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int64
+	cache := New(ctx, time.Hour, func() (int64, error) {
+		return atomic.AddInt64(&calls, 1), nil
+	}, WithoutGlobalRegistration[int64](), WithName[int64]("test-unregistered"))
+	defer cache.Close()
+
+	GlobalCacheUpdate()
+	if got := atomic.LoadInt64(&calls); got != 1 {
+		t.Errorf("updateFunc calls after GlobalCacheUpdate() = %v, want %v", got, 1)
+	}
+	if _, ok := LookupCache("test-unregistered"); ok {
+		t.Errorf("LookupCache() found an unregistered cache")
+	}
+
+	// Its own updates work as usual
+	cache.Update()
+	if got := cache.Get(); got != 2 {
+		t.Errorf("Get() after Update() = %v, want %v", got, 2)
+	}
+}
+
+func TestGlobalCacheClose(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int64
+	caches := make([]ReCached[int64], 0, 5)
+	for i := 0; i < 5; i++ {
+		caches = append(caches, New(ctx, time.Hour, func() (int64, error) {
+			return atomic.AddInt64(&calls, 1), nil
+		}))
+	}
+
+	GlobalCacheClose()
+
+	// Every cache is closed and the registry is empty
+	for i, cache := range caches {
+		if err := cache.ForceUpdate(); !errors.Is(err, ErrClosed) {
+			t.Errorf("ForceUpdate() of cache %d = %v, want %v", i, err, ErrClosed)
+		}
+	}
+	GlobalCacheUpdate()
+	if got := atomic.LoadInt64(&calls); got != 5 {
+		t.Errorf("updateFunc calls = %v, want %v", got, 5)
+	}
+	if got := len(registeredCaches()); got != 0 {
+		t.Errorf("Registered caches after GlobalCacheClose() = %v, want 0", got)
+	}
+}
+
+func TestRefreshTag(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var pricing, config int64
+	pricingCache := New(ctx, time.Hour, func() (int64, error) {
+		return atomic.AddInt64(&pricing, 1), nil
+	}, WithTags[int64]("pricing", "hot"))
+	defer pricingCache.Close()
+	configCache := New(ctx, time.Hour, func() (int64, error) {
+		return atomic.AddInt64(&config, 1), nil
+	}, WithTags[int64]("config"))
+	defer configCache.Close()
+
+	if err := RefreshTag("pricing"); err != nil {
+		t.Errorf("RefreshTag() = %v, want nil", err)
+	}
+
+	// Only the tagged cache is refreshed
+	if got := atomic.LoadInt64(&pricing); got != 2 {
+		t.Errorf("Tagged cache updates = %v, want %v", got, 2)
+	}
+	if got := atomic.LoadInt64(&config); got != 1 {
+		t.Errorf("Untagged cache updates = %v, want %v", got, 1)
+	}
+}
+
+func TestListCaches(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updateErr := errors.New("update failed")
+	failing := New(ctx, time.Hour, func() (int, error) {
+		return 0, updateErr
+	}, WithName[int]("b"))
+	defer failing.Close()
+	working := New(ctx, time.Hour, func() (string, error) {
+		return "value", nil
+	}, WithName[string]("a"), WithTags[string]("config"))
+	defer working.Close()
+
+	if got := CacheCount(); got != 2 {
+		t.Errorf("CacheCount() = %v, want %v", got, 2)
+	}
+
+	infos := ListCaches()
+	if len(infos) != 2 {
+		t.Fatalf("ListCaches() returned %v caches, want %v", len(infos), 2)
+	}
+	a, b := infos[0], infos[1]
+	if a.Name != "a" || !slices.Equal(a.Tags, []string{"config"}) || !a.Ready || a.Version != 1 || a.LastError != nil {
+		t.Errorf("ListCaches()[0] = %+v, want the working cache", a)
+	}
+	if b.Name != "b" || b.Ready || b.Age != 0 || b.Version != 0 || !errors.Is(b.LastError, updateErr) {
+		t.Errorf("ListCaches()[1] = %+v, want the failing cache", b)
+	}
+
+	working.Close()
+	if got := CacheCount(); got != 1 {
+		t.Errorf("CacheCount() after Close() = %v, want %v", got, 1)
+	}
+}
+
+func TestWithSingleton(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int64
+	updateFunc := func() (int64, error) {
+		return calls.Add(1), nil
+	}
+
+	// Concurrent constructions share a single cache
+	var wg sync.WaitGroup
+	caches := make([]ReCached[int64], 10)
+	for i := range caches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			caches[i] = New(ctx, time.Hour, updateFunc, WithSingleton[int64]("singleton"))
+		}()
+	}
+	wg.Wait()
+	defer caches[0].Close()
+
+	for i, cache := range caches {
+		if cache != caches[0] {
+			t.Errorf("Construction %d returned another cache", i)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("updateFunc calls = %v, want %v", got, 1)
+	}
+	if got := CacheCount(); got != 1 {
+		t.Errorf("CacheCount() = %v, want %v", got, 1)
+	}
+
+	// The name cannot be reused for another value type
+	if _, err := NewOrError(ctx, time.Hour, func() (string, error) {
+		return "", nil
+	}, WithSingleton[string]("singleton")); err == nil {
+		t.Errorf("NewOrError() with another value type = nil, want an error")
+	}
+}
+
+func TestNameOfCancelledCache(t *testing.T) {
+	isolateRegistry(t)
+
+	updateFunc := func() (int, error) {
+		return 1, nil
+	}
+
+	// A cancelled cache frees its name at once, before its loop deregisters it
+	for range 20 {
+		ctx, cancel := context.WithCancel(context.Background())
+		cache := New(ctx, time.Hour, updateFunc, WithName[int]("test-cancelled"))
+		cancel()
+		if _, ok := LookupCache("test-cancelled"); ok {
+			t.Fatalf("LookupCache() found a cache with a cancelled context")
+		}
+		reused, err := NewOrError(context.Background(), time.Hour, updateFunc, WithName[int]("test-cancelled"))
+		if err != nil {
+			t.Fatalf("NewOrError() reusing the name of a cancelled cache = %v, want nil", err)
+		}
+		cache.Close()
+		reused.Close()
+	}
+
+	// WithSingleton does not return a cancelled cache
+	ctx, cancel := context.WithCancel(context.Background())
+	dead := New(ctx, time.Hour, updateFunc, WithSingleton[int]("test-cancelled-singleton"))
+	defer dead.Close()
+	cancel()
+	live := New(context.Background(), time.Hour, updateFunc, WithSingleton[int]("test-cancelled-singleton"))
+	defer live.Close()
+	if live == dead {
+		t.Errorf("WithSingleton returned the cache with a cancelled context")
+	}
+	if got, ok := LookupCache("test-cancelled-singleton"); !ok || got != live {
+		t.Errorf("LookupCache() = %v, %v, want the new singleton", got, ok)
+	}
+}
+
+func TestGlobalCacheUpdateFor(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tenantA := Scope(ctx)
+	tenantB := Scope(ctx)
+	nested := Scope(tenantA)
+
+	var a, inner, b, global atomic.Int64
+	newCache := func(ctx context.Context, calls *atomic.Int64) {
+		cache := New(ctx, time.Hour, func() (int64, error) {
+			return calls.Add(1), nil
+		})
+		t.Cleanup(cache.Close)
+	}
+	// A context derived from a scope belongs to it
+	requestCtx, requestCancel := context.WithCancel(tenantA)
+	defer requestCancel()
+	newCache(requestCtx, &a)
+	newCache(nested, &inner)
+	newCache(tenantB, &b)
+	newCache(ctx, &global)
+
+	if err := GlobalCacheUpdateFor(tenantA); err != nil {
+		t.Errorf("GlobalCacheUpdateFor() = %v, want nil", err)
+	}
+	for name, tc := range map[string]struct {
+		calls *atomic.Int64
+		want  int64
+	}{
+		"scoped":   {&a, 2},
+		"nested":   {&inner, 2},
+		"other":    {&b, 1},
+		"unscoped": {&global, 1},
+	} {
+		if got := tc.calls.Load(); got != tc.want {
+			t.Errorf("updateFunc calls of the %s cache = %v, want %v", name, got, tc.want)
+		}
+	}
+
+	// Without a scope nothing is updated
+	if err := GlobalCacheUpdateFor(ctx); err != nil {
+		t.Errorf("GlobalCacheUpdateFor() without a scope = %v, want nil", err)
+	}
+	if got := global.Load(); got != 1 {
+		t.Errorf("updateFunc calls without a scope = %v, want %v", got, 1)
+	}
+}
+
+func TestTrimRegistry(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	live := New(ctx, time.Hour, func() (int, error) {
+		return 1, nil
+	}, WithName[int]("live"))
+	defer live.Close()
+
+	// Registered without an update loop, so nothing but TrimRegistry deregisters it
+	deadCtx, deadCancel := context.WithCancel(ctx)
+	dead := newReCached(deadCtx, time.Hour, func(context.Context) (int, error) {
+		return 1, nil
+	}, WithName[int]("dead"))
+	if err := register(dead); err != nil {
+		t.Fatalf("register() = %v", err)
+	}
+	defer dead.Close()
+
+	if got := TrimRegistry(); got != 0 {
+		t.Errorf("TrimRegistry() with live caches = %v, want 0", got)
+	}
+	deadCancel()
+	if got := TrimRegistry(); got != 1 {
+		t.Errorf("TrimRegistry() = %v, want %v", got, 1)
+	}
+	if _, ok := LookupCache("dead"); ok {
+		t.Errorf("LookupCache() found a trimmed cache")
+	}
+	if _, ok := LookupCache("live"); !ok {
+		t.Errorf("LookupCache() did not find a live cache after TrimRegistry()")
+	}
+}
+
+func TestGlobalCacheUpdateSkipsDone(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var calls atomic.Int64
+	dead := newReCached(ctx, time.Hour, func(context.Context) (int64, error) {
+		return calls.Add(1), nil
+	})
+	if err := register(dead); err != nil {
+		t.Fatalf("register() = %v", err)
+	}
+	defer dead.Close()
+
+	GlobalCacheUpdate()
+	if got := calls.Load(); got != 0 {
+		t.Errorf("updateFunc calls of a cache with a done context = %v, want 0", got)
+	}
+	if got := CacheCount(); got != 0 {
+		t.Errorf("CacheCount() after GlobalCacheUpdate() = %v, want 0", got)
+	}
+}
+
+func TestGlobalCacheUpdateReentrant(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Updating the first cache registers another one from its callback, but only once armed,
+	// so it is not done by the initial update
+	var armed atomic.Bool
+	var child ReCached[int]
+	parent := New(ctx, time.Hour, func() (int, error) {
+		return 1, nil
+	}, WithOnUpdate(func(old, new int) {
+		if armed.CompareAndSwap(true, false) {
+			child = New(ctx, time.Hour, func() (int, error) {
+				return 2, nil
+			}, WithName[int]("child"))
+		}
+	}))
+	defer parent.Close()
+
+	armed.Store(true)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		GlobalCacheUpdate()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("GlobalCacheUpdate() did not return when a cache was registered during the update")
+	}
+	if child == nil {
+		t.Fatalf("OnUpdate callback did not create the cache")
+	}
+	defer child.Close()
+
+	if _, ok := LookupCache("child"); !ok {
+		t.Errorf("LookupCache() did not find the cache registered during the update")
+	}
+	if got := CacheCount(); got != 2 {
+		t.Errorf("CacheCount() = %v, want %v", got, 2)
+	}
+}
+
+func TestNewWithCancel(t *testing.T) {
+	isolateRegistry(t)
+
+	var calls atomic.Int64
+	cache, cancel := NewWithCancel(context.Background(), time.Hour, func() (int64, error) {
+		return calls.Add(1), nil
+	}, WithName[int64]("cancelled"))
+	defer cache.Close()
+
+	// Cancelling deregisters the cache right away
+	cancel()
+	if _, ok := LookupCache("cancelled"); ok {
+		t.Errorf("LookupCache() found a cancelled cache")
+	}
+	if got := CacheCount(); got != 0 {
+		t.Errorf("CacheCount() after cancel = %v, want 0", got)
+	}
+
+	// Explicit updates keep working
+	cache.Update()
+	if got := cache.Get(); got != 2 {
+		t.Errorf("Get() after Update() = %v, want %v", got, 2)
+	}
+
+	// A singleton running in another context is not affected
+	shared := New(context.Background(), time.Hour, func() (int64, error) {
+		return 1, nil
+	}, WithSingleton[int64]("shared"))
+	defer shared.Close()
+	same, cancelSame := NewWithCancel(context.Background(), time.Hour, func() (int64, error) {
+		return 1, nil
+	}, WithSingleton[int64]("shared"))
+	cancelSame()
+	if same != shared {
+		t.Errorf("NewWithCancel() with WithSingleton returned another cache")
+	}
+	if _, ok := LookupCache("shared"); !ok {
+		t.Errorf("LookupCache() did not find the singleton after cancelling another construction")
+	}
+}
+
+func TestGlobalCacheUpdateJoinsInFlight(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	var calls atomic.Int64
+	started := make(chan struct{})
+	release := make(chan struct{})
+	cache := New(ctx, time.Minute, func() (int64, error) {
+		n := calls.Add(1)
+		if n == 2 {
+			close(started)
+			<-release
+		}
+		return n, nil
+	}, WithClock[int64](clock))
+	defer cache.Close()
+	<-clock.created
+
+	// A slow automatic update is in flight when the global update starts
+	clock.Advance(time.Minute)
+	<-started
+
+	done := make(chan error, 1)
+	go func() {
+		done <- GlobalCacheUpdateContext(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("GlobalCacheUpdateContext() = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("GlobalCacheUpdateContext() did not return after the in-flight update finished")
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("updateFunc calls = %v, want %v", got, 2)
+	}
+	if got := cache.Get(); got != 2 {
+		t.Errorf("Get() after the shared update = %v, want %v", got, 2)
+	}
+}
@@ -0,0 +1,47 @@
+package recached
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RetryPolicy retries failed calls of the update function within a single update, see WithUpdateRetry
+type RetryPolicy struct {
+	// Attempts is the maximum number of calls per update, 1 or less means no retries
+	Attempts int
+	// Delay is the wait before the first retry, it doubles with every further retry
+	Delay time.Duration
+	// Retryable reports whether a call failing with err is retried, nil retries every error
+	Retryable func(err error) bool
+}
+
+// retryable reports whether a call failing with err is retried
+func (p RetryPolicy) retryable(err error) bool {
+	// An unchanged value is not a failure, see NewConditional
+	if errors.Is(err, errNotModified) {
+		return false
+	}
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// callRetried calls the sources like callSources, retrying failures as set by WithUpdateRetry.
+// It stops retrying when ctx is done and returns the error of the last call
+func (r *reCached[T]) callRetried(ctx context.Context, updateFunc func(ctx context.Context) (T, error)) (T, int, error) {
+	value, source, err := r.callSources(ctx, updateFunc)
+	delay := r.retry.Delay
+	for attempt := 1; err != nil && attempt < r.retry.Attempts && r.retry.retryable(err); attempt++ {
+		r.log("%s: update failed, retrying in %v: %v", r, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return value, source, err
+		}
+		value, source, err = r.callSources(ctx, updateFunc)
+		delay *= 2
+	}
+	return value, source, err
+}
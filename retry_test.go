@@ -0,0 +1,89 @@
+package recached
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithUpdateRetry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	transient := errors.New("connection reset")
+	permanent := errors.New("permission denied")
+	var calls, failures int
+	var failWith error
+	cache := New(ctx, time.Hour, func() (int, error) {
+		calls++
+		if failures > 0 {
+			failures--
+			return 0, failWith
+		}
+		return calls, nil
+	}, WithUpdateRetry[int](RetryPolicy{
+		Attempts:  3,
+		Delay:     time.Millisecond,
+		Retryable: func(err error) bool { return !errors.Is(err, permanent) },
+	}))
+	defer cache.Close()
+
+	// Transient failures are retried within a single update
+	calls, failures, failWith = 0, 2, transient
+	if err := cache.ForceUpdate(); err != nil {
+		t.Errorf("ForceUpdate() with transient failures = %v, want nil", err)
+	}
+	if got := cache.Get(); got != 3 {
+		t.Errorf("Get() after the retries = %v, want %v", got, 3)
+	}
+	if got := cache.Stats().Failures; got != 0 {
+		t.Errorf("Stats().Failures after a retried update = %v, want %v", got, 0)
+	}
+
+	// The last error is returned after all attempts
+	calls, failures = 0, 5
+	if err := cache.ForceUpdate(); !errors.Is(err, transient) {
+		t.Errorf("ForceUpdate() failing every attempt = %v, want %v", err, transient)
+	}
+	if calls != 3 {
+		t.Errorf("Calls failing every attempt = %v, want %v", calls, 3)
+	}
+
+	// Errors the policy does not classify as retryable fail right away
+	calls, failures, failWith = 0, 1, permanent
+	if err := cache.ForceUpdate(); !errors.Is(err, permanent) {
+		t.Errorf("ForceUpdate() with a permanent failure = %v, want %v", err, permanent)
+	}
+	if calls != 1 {
+		t.Errorf("Calls with a permanent failure = %v, want %v", calls, 1)
+	}
+}
+
+func TestWithUpdateRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updateErr := errors.New("update failed")
+	var fail bool
+	cache := NewCtx(ctx, time.Hour, func(context.Context) (int, error) {
+		if fail {
+			return 0, updateErr
+		}
+		return 1, nil
+	}, WithUpdateRetry[int](RetryPolicy{Attempts: 3, Delay: time.Hour}), WithTimeout[int](20*time.Millisecond))
+	defer cache.Close()
+
+	// The timeout of the update cuts the wait for the next attempt short
+	fail = true
+	start := time.Now()
+	if err := cache.ForceUpdate(); !errors.Is(err, updateErr) {
+		t.Errorf("ForceUpdate() = %v, want %v", err, updateErr)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("ForceUpdate() took %v, want the retries to stop with the context", elapsed)
+	}
+	if got := cache.Get(); got != 1 {
+		t.Errorf("Get() after the cancelled retries = %v, want %v", got, 1)
+	}
+}
@@ -0,0 +1,109 @@
+package recached
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// GlobalSnapshot encodes the current values of all registered named caches having a codec,
+// see WithCodec, so GlobalRestore can load them again, e.g. after a restart.
+// Caches without a value are left out. Failed encodings are returned as joined errors
+// prefixed with the cache, the other caches are still included in the snapshot
+func GlobalSnapshot() ([]byte, error) {
+	values := make(map[string][]byte)
+	var errs []error
+	for _, cache := range registeredCaches() {
+		name := cache.cacheName()
+		if name == "" {
+			continue
+		}
+		data, ok, err := cache.snapshot()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cache, err))
+			continue
+		}
+		if ok {
+			values[name] = data
+		}
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	return data, errors.Join(errs...)
+}
+
+// GlobalRestore sets the registered caches to the values of a snapshot made by GlobalSnapshot,
+// matching them by name. Values of names without a registered cache or without a codec are
+// skipped with a warning in the default logger, see SetDefaultLogger. Failed decodings are returned as joined errors
+// prefixed with the cache, the other caches are still restored
+func GlobalRestore(data []byte) error {
+	var values map[string][]byte
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("recached: decoding snapshot: %w", err)
+	}
+
+	var errs []error
+	for name, value := range values {
+		cache, ok := lookup(name)
+		if !ok {
+			if l := currentDefaultLogger(); l != nil {
+				l.Printf("recached: snapshot of unknown cache %q ignored", name)
+			}
+			continue
+		}
+		ok, err := cache.restore(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cache, err))
+			continue
+		}
+		if l := currentDefaultLogger(); !ok && l != nil {
+			l.Printf("%s: snapshot ignored, the cache has no codec", cache)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// snapshotCodec returns the codec set by WithCodec or WithPersistence, nil if there is none
+func (r *reCached[T]) snapshotCodec() Codec[T] {
+	if r.codec != nil {
+		return r.codec
+	}
+	if r.file != nil {
+		return r.file.codec
+	}
+	return nil
+}
+
+// snapshot encodes the current value. It reports false if there is no codec or no value
+func (r *reCached[T]) snapshot() ([]byte, bool, error) {
+	codec := r.snapshotCodec()
+	if codec == nil {
+		return nil, false, nil
+	}
+	value := r.current.Load()
+	if value == nil {
+		return nil, false, nil
+	}
+	data, err := codec.Marshal(*value)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// restore decodes data and sets it as the value. It reports false if there is no codec
+func (r *reCached[T]) restore(data []byte) (bool, error) {
+	codec := r.snapshotCodec()
+	if codec == nil {
+		return false, nil
+	}
+	value, err := codec.Unmarshal(data)
+	if err != nil {
+		return false, err
+	}
+	r.Set(value)
+	return true, nil
+}
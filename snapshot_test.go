@@ -0,0 +1,129 @@
+package recached
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGlobalSnapshot(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	New(ctx, time.Hour, func() ([]string, error) {
+		return []string{"alice", "bob"}, nil
+	}, WithName[[]string]("users"), WithCodec[[]string](JSONCodec[[]string]{}))
+	New(ctx, time.Hour, func() (int, error) {
+		return 42, nil
+	}, WithName[int]("limit"), WithCodec[int](GobCodec[int]{}))
+	// Caches without a codec or without a value are left out
+	New(ctx, time.Hour, func() (int, error) {
+		return 1, nil
+	}, WithName[int]("plain"))
+	New(ctx, time.Hour, func() (int, error) {
+		return 0, errors.New("source is down")
+	}, WithName[int]("empty"), WithCodec[int](JSONCodec[int]{}))
+
+	data, err := GlobalSnapshot()
+	if err != nil {
+		t.Fatalf("GlobalSnapshot() error = %v", err)
+	}
+	GlobalCacheClose()
+
+	// Restore into fresh caches whose sources are not available yet
+	down := errors.New("source is down")
+	restoredUsers := New(ctx, time.Hour, func() ([]string, error) {
+		return nil, down
+	}, WithName[[]string]("users"), WithCodec[[]string](JSONCodec[[]string]{}))
+	defer restoredUsers.Close()
+	restoredLimit := New(ctx, time.Hour, func() (int, error) {
+		return 0, down
+	}, WithName[int]("limit"), WithCodec[int](GobCodec[int]{}), WithLazy[int]())
+	defer restoredLimit.Close()
+	restoredPlain := New(ctx, time.Hour, func() (int, error) {
+		return 0, down
+	}, WithName[int]("plain"), WithCodec[int](JSONCodec[int]{}))
+	defer restoredPlain.Close()
+
+	if err := GlobalRestore(data); err != nil {
+		t.Fatalf("GlobalRestore() error = %v", err)
+	}
+	if got := restoredUsers.Get(); strings.Join(got, ",") != "alice,bob" {
+		t.Errorf("Get() of users after GlobalRestore() = %v, want [alice bob]", got)
+	}
+	if got := restoredLimit.Get(); got != 42 {
+		t.Errorf("Get() of limit after GlobalRestore() = %v, want %v", got, 42)
+	}
+	if got := restoredPlain.Get(); got != 0 {
+		t.Errorf("Get() of a cache snapshotted without a codec = %v, want %v", got, 0)
+	}
+}
+
+func TestGlobalRestoreUnknown(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	plain := New(ctx, time.Hour, func() (int, error) {
+		return 1, nil
+	}, WithName[int]("plain"))
+	defer plain.Close()
+
+	// Without a default logger nothing is logged, not even by the standard logger
+	var buf bytes.Buffer
+	output := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(output)
+	if err := GlobalRestore([]byte(`{"missing":"MQ=="}`)); err != nil {
+		t.Errorf("GlobalRestore() with an unknown name = %v, want nil", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("GlobalRestore() without a default logger logged %q, want nothing", buf.String())
+	}
+
+	// The default logger is warned about unknown names and caches without a codec
+	logger := &testLogger{}
+	SetDefaultLogger(logger)
+	t.Cleanup(func() { SetDefaultLogger(nil) })
+	if err := GlobalRestore([]byte(`{"missing":"MQ==","plain":"Mg=="}`)); err != nil {
+		t.Errorf("GlobalRestore() with an unknown name = %v, want nil", err)
+	}
+	logged := strings.Join(logger.messages, "\n")
+	if !strings.Contains(logged, `"missing"`) || !strings.Contains(logged, "recached[plain]: snapshot ignored") {
+		t.Errorf("GlobalRestore() logged %q, want warnings about the unknown name and the cache without a codec", logged)
+	}
+	if got := plain.Get(); got != 1 {
+		t.Errorf("Get() of a cache without a codec after GlobalRestore() = %v, want %v", got, 1)
+	}
+
+	if err := GlobalRestore([]byte("not json")); err == nil {
+		t.Errorf("GlobalRestore() with invalid data = nil, want an error")
+	}
+}
+
+func TestGlobalRestoreDecodeError(t *testing.T) {
+	isolateRegistry(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cache := New(ctx, time.Hour, func() (int, error) {
+		return 1, nil
+	}, WithName[int]("limit"), WithCodec[int](JSONCodec[int]{}))
+	defer cache.Close()
+
+	// "bm90IGEgbnVtYmVy" is "not a number"
+	if err := GlobalRestore([]byte(`{"limit":"bm90IGEgbnVtYmVy"}`)); err == nil || !strings.HasPrefix(err.Error(), "recached[limit]: ") {
+		t.Errorf("GlobalRestore() with an invalid value = %v, want an error naming the cache", err)
+	}
+	if got := cache.Get(); got != 1 {
+		t.Errorf("Get() after a failed restore = %v, want %v", got, 1)
+	}
+}
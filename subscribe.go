@@ -0,0 +1,163 @@
+package recached
+
+import "sync"
+
+// subscriberBuffer is the default channel buffer of every subscriber
+const subscriberBuffer = 1
+
+// Overflow decides what happens to a new value when the buffer of a subscriber is full, see SubscribeOptions
+type Overflow int
+
+const (
+	// DropNewest drops the new value, the subscriber keeps the values already buffered
+	DropNewest Overflow = iota
+	// DropOldest drops the oldest buffered value to make room for the new one,
+	// so the subscriber always gets the latest value
+	DropOldest
+	// Block waits until the subscriber has room for the new value, so no value is lost.
+	// A slow subscriber delays the notifications and callbacks of the update until it catches up
+	Block
+)
+
+func (o Overflow) String() string {
+	switch o {
+	case DropNewest:
+		return "drop-newest"
+	case DropOldest:
+		return "drop-oldest"
+	case Block:
+		return "block"
+	default:
+		return "unknown"
+	}
+}
+
+// SubscribeOptions configure a subscription, see ReCached.SubscribeWith.
+// The zero value is a subscription like Subscribe
+type SubscribeOptions struct {
+	// Buffer is the channel buffer, 0 or less means the default of 1
+	Buffer int
+	// Overflow is the policy when the buffer is full
+	Overflow Overflow
+}
+
+// subscriber is a subscribed channel with its policy
+type subscriber[T any] struct {
+	ch       chan T
+	overflow Overflow
+	// done is closed on unsubscribe, so blocked sends give up before ch is closed
+	done    chan struct{}
+	sending sync.WaitGroup
+}
+
+// subscribers fans out values to subscribed channels
+type subscribers[T any] struct {
+	mu     sync.Mutex
+	closed bool
+	subs   map[*subscriber[T]]struct{}
+}
+
+// subscribe adds a channel and returns it with a function removing it again
+func (s *subscribers[T]) subscribe(opts SubscribeOptions) (<-chan T, func()) {
+	buffer := opts.Buffer
+	if buffer <= 0 {
+		buffer = subscriberBuffer
+	}
+	sub := &subscriber[T]{
+		ch:       make(chan T, buffer),
+		overflow: opts.Overflow,
+		done:     make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+	if s.subs == nil {
+		s.subs = make(map[*subscriber[T]]struct{})
+	}
+	s.subs[sub] = struct{}{}
+
+	return sub.ch, func() {
+		s.mu.Lock()
+		_, ok := s.subs[sub]
+		delete(s.subs, sub)
+		s.mu.Unlock()
+		if ok {
+			sub.close()
+		}
+	}
+}
+
+// close closes the channel of a removed subscriber, once a blocked send has given up
+func (sub *subscriber[T]) close() {
+	close(sub.done)
+	sub.sending.Wait()
+	close(sub.ch)
+}
+
+// publish sends value to every subscriber according to its overflow policy.
+// Blocking sends happen without the lock, so unsubscribing never waits for them
+func (s *subscribers[T]) publish(value T) {
+	var blocking []*subscriber[T]
+
+	s.mu.Lock()
+	for sub := range s.subs {
+		switch sub.overflow {
+		case Block:
+			// Added under the lock, so close cannot miss a send that is about to start
+			sub.sending.Add(1)
+			blocking = append(blocking, sub)
+		case DropOldest:
+			// Only publish sends, so after dropping a value there is room for the new one
+			for sent := false; !sent; {
+				select {
+				case sub.ch <- value:
+					sent = true
+				default:
+					select {
+					case <-sub.ch:
+					default:
+					}
+				}
+			}
+		default:
+			select {
+			case sub.ch <- value:
+			default:
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	for _, sub := range blocking {
+		select {
+		case sub.ch <- value:
+		case <-sub.done:
+		}
+		sub.sending.Done()
+	}
+}
+
+// active reports whether there are subscribers
+func (s *subscribers[T]) active() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs) > 0
+}
+
+// close closes all subscribed channels, later subscriptions get a closed channel
+func (s *subscribers[T]) close() {
+	s.mu.Lock()
+	s.closed = true
+	subs := s.subs
+	s.subs = nil
+	s.mu.Unlock()
+
+	for sub := range subs {
+		sub.close()
+	}
+}
@@ -0,0 +1,158 @@
+package recached
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	value := 0
+	cache := New(ctx, time.Hour, func() (int, error) {
+		value++
+		return value, nil
+	})
+
+	first, _ := cache.Subscribe()
+	second, unsubscribe := cache.Subscribe()
+
+	// Every subscriber receives the new value
+	cache.Update()
+	for i, ch := range []<-chan int{first, second} {
+		select {
+		case got := <-ch:
+			if got != 2 {
+				t.Errorf("Subscriber %d received %v, want %v", i, got, 2)
+			}
+		case <-time.After(500 * time.Millisecond):
+			t.Fatalf("Timed out waiting for subscriber %d", i)
+		}
+	}
+
+	// A slow subscriber does not block updates
+	cache.Update()
+	cache.Update()
+	if got := len(first); got != subscriberBuffer {
+		t.Errorf("Buffered values = %v, want %v", got, subscriberBuffer)
+	}
+
+	// Unsubscribing closes the channel, calling it again is harmless
+	unsubscribe()
+	unsubscribe()
+	for range second {
+	}
+
+	// Close closes the remaining channels
+	cache.Close()
+	for range first {
+	}
+	if _, ok := <-first; ok {
+		t.Errorf("Subscriber channel is still open after Close()")
+	}
+}
+
+func TestSubscribeWith(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	value := 0
+	cache := New(ctx, time.Hour, func() (int, error) {
+		value++
+		return value, nil
+	})
+	defer cache.Close()
+
+	newest, _ := cache.SubscribeWith(SubscribeOptions{Buffer: 2, Overflow: DropNewest})
+	oldest, _ := cache.SubscribeWith(SubscribeOptions{Buffer: 2, Overflow: DropOldest})
+	for range 3 {
+		cache.Update()
+	}
+
+	// DropNewest keeps the first values, DropOldest the latest ones
+	for _, tt := range []struct {
+		ch   <-chan int
+		want []int
+	}{
+		{newest, []int{2, 3}},
+		{oldest, []int{3, 4}},
+	} {
+		for _, want := range tt.want {
+			if got := <-tt.ch; got != want {
+				t.Errorf("Subscriber received %v, want %v", got, want)
+			}
+		}
+	}
+}
+
+func TestSubscribeWithBlock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	value := 0
+	cache := New(ctx, time.Hour, func() (int, error) {
+		value++
+		return value, nil
+	})
+	defer cache.Close()
+
+	// A blocking subscriber receives every value
+	ch, unsubscribe := cache.SubscribeWith(SubscribeOptions{Overflow: Block})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 3 {
+			cache.Update()
+		}
+	}()
+	for want := 2; want <= 4; want++ {
+		select {
+		case got := <-ch:
+			if got != want {
+				t.Errorf("Subscriber received %v, want %v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for value %v", want)
+		}
+	}
+	<-done
+
+	// An update blocked by the subscriber is released by unsubscribing
+	cache.Update()
+	updated := make(chan struct{})
+	go func() {
+		cache.Update()
+		close(updated)
+	}()
+	select {
+	case <-updated:
+		t.Fatal("Update() returned while the subscriber was full")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unsubscribe()
+	select {
+	case <-updated:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the blocked update after unsubscribing")
+	}
+	for range ch {
+	}
+
+	// Close releases a blocked update as well
+	_, _ = cache.SubscribeWith(SubscribeOptions{Overflow: Block})
+	cache.Update()
+	updated = make(chan struct{})
+	go func() {
+		cache.Update()
+		close(updated)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cache.Close()
+	select {
+	case <-updated:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the blocked update after Close()")
+	}
+}
@@ -0,0 +1,113 @@
+package recached
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// view is a read-only projection of another cache, see Map.
+// The methods not involving the value are promoted from the source
+type view[T, U any] struct {
+	ReCached[T]
+	f func(T) U
+}
+
+// Map returns a read-only view of src projecting its value with f, e.g. the count of cached users.
+// The view has no update loop of its own: f runs on every read of the value, so it should be cheap,
+// and the view is always consistent with src. Updates and the other reads act on src, while Set,
+// SetUpdateFunc, Reset, SetPeriod, Pause, Resume and Close are ignored, so only the owner of src
+// controls its lifecycle. It is the same cache as src for DependsOn
+func Map[T, U any](src ReCached[T], f func(T) U) ReCached[U] {
+	return view[T, U]{ReCached: src, f: f}
+}
+
+func (v view[T, U]) Get() U {
+	return v.f(v.ReCached.Get())
+}
+
+func (v view[T, U]) GetWithError() (U, error) {
+	value, err := v.ReCached.GetWithError()
+	return v.f(value), err
+}
+
+func (v view[T, U]) GetOK() (U, bool) {
+	value, ok := v.ReCached.GetOK()
+	return v.f(value), ok
+}
+
+func (v view[T, U]) GetFresh(maxAge time.Duration) (U, error) {
+	value, err := v.ReCached.GetFresh(maxAge)
+	return v.f(value), err
+}
+
+func (v view[T, U]) GetSWR(softAge time.Duration) U {
+	return v.f(v.ReCached.GetSWR(softAge))
+}
+
+func (v view[T, U]) GetVersioned() (U, uint64) {
+	value, version := v.ReCached.GetVersioned()
+	return v.f(value), version
+}
+
+func (v view[T, U]) GetWithMeta() (U, Meta) {
+	value, meta := v.ReCached.GetWithMeta()
+	return v.f(value), meta
+}
+
+func (v view[T, U]) WaitForVersion(ctx context.Context, version uint64) (U, error) {
+	value, err := v.ReCached.WaitForVersion(ctx, version)
+	return v.f(value), err
+}
+
+// Set is ignored, a projected value cannot be stored in the source
+func (v view[T, U]) Set(U) {}
+
+// SetUpdateFunc is ignored, the source keeps its update function
+func (v view[T, U]) SetUpdateFunc(func() (U, error)) {}
+
+// Reset is ignored, the value belongs to the source
+func (v view[T, U]) Reset() {}
+
+// SetPeriod is ignored, the source keeps its period
+func (v view[T, U]) SetPeriod(time.Duration) {}
+
+// Pause is ignored, only the owner of the source pauses it
+func (v view[T, U]) Pause() {}
+
+// Resume is ignored, only the owner of the source resumes it
+func (v view[T, U]) Resume() {}
+
+// Close is ignored, only the owner of the source closes it
+func (v view[T, U]) Close() {}
+
+func (v view[T, U]) Subscribe() (<-chan U, func()) {
+	return v.SubscribeWith(SubscribeOptions{})
+}
+
+// SubscribeWith subscribes to src with opts and projects every value it receives.
+// The projected channel is unbuffered, so values wait in the buffer of the source subscription
+func (v view[T, U]) SubscribeWith(opts SubscribeOptions) (<-chan U, func()) {
+	in, unsubscribe := v.ReCached.SubscribeWith(opts)
+	out := make(chan U)
+	stop := make(chan struct{})
+
+	go func() {
+		defer close(out)
+		for value := range in {
+			select {
+			case out <- v.f(value):
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return out, func() {
+		once.Do(func() {
+			close(stop)
+			unsubscribe()
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package recached
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	users := []string{"ann", "bob"}
+	var updates int
+	src := New(ctx, time.Hour, func() ([]string, error) {
+		updates++
+		return users, nil
+	}, WithoutGlobalRegistration[[]string]())
+	defer src.Close()
+
+	count := Map(src, func(users []string) int { return len(users) })
+	if got := count.Get(); got != 2 {
+		t.Errorf("Get() = %v, want %v", got, 2)
+	}
+
+	// Updates forward to the source, the view follows it without a loop of its own
+	ch, unsubscribe := count.Subscribe()
+	users = append(users, "cid")
+	count.Update()
+	if updates != 2 {
+		t.Errorf("Updates of the source = %v, want %v", updates, 2)
+	}
+	if got, version := count.GetVersioned(); got != 3 || version != src.Version() {
+		t.Errorf("GetVersioned() = %v, %v, want %v, %v", got, version, 3, src.Version())
+	}
+	select {
+	case got := <-ch:
+		if got != 3 {
+			t.Errorf("Subscriber received %v, want %v", got, 3)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the projected value")
+	}
+
+	// Unsubscribing closes the projected channel, calling it again is harmless
+	unsubscribe()
+	unsubscribe()
+	for range ch {
+	}
+
+	// The view is read-only
+	count.Set(10)
+	if got := count.Get(); got != 3 {
+		t.Errorf("Get() after Set() = %v, want %v", got, 3)
+	}
+
+	// Nor does it control the lifecycle of the source
+	count.Reset()
+	count.SetPeriod(time.Minute)
+	count.Pause()
+	count.Close()
+	if !src.Ready() || src.Period() != time.Hour {
+		t.Errorf("Source after Reset() and SetPeriod() of the view: ready=%v period=%v", src.Ready(), src.Period())
+	}
+	waitFor(t, func() bool { return !src.NextRefresh().IsZero() }, "the source to schedule automatic updates")
+	if err := src.ForceUpdate(); err != nil {
+		t.Errorf("ForceUpdate() of the source after Close() of the view = %v, want nil", err)
+	}
+
+	// The view is the same cache as the source for dependencies
+	if err := src.DependsOn(count); !errors.Is(err, ErrCycle) {
+		t.Errorf("DependsOn() of the view = %v, want %v", err, ErrCycle)
+	}
+
+	// Closing the source closes the subscriptions of the view
+	ch, _ = count.Subscribe()
+	src.Close()
+	for range ch {
+	}
+}
@@ -0,0 +1,37 @@
+package recached
+
+import (
+	"context"
+	"sync"
+)
+
+// WarmAll waits until all caches are ready, see ReCached.WaitReady, i.e. have loaded a value at least once.
+// The caches created with WithLazy start loading.
+// Caches of different value types can be mixed. It returns nil once all of them are ready,
+// or the first error otherwise, usually because ctx is done, without waiting for the rest
+func WarmAll(ctx context.Context, caches ...interface {
+	WaitReady(ctx context.Context) error
+}) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for _, cache := range caches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cache.WaitReady(ctx); err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
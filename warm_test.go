@@ -0,0 +1,77 @@
+package recached
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWarmAll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ints := New(ctx, time.Hour, func() (int, error) {
+		return 1, nil
+	}, WithLazyNoWait[int]())
+	defer ints.Close()
+	strs := New(ctx, time.Hour, func() (string, error) {
+		return "a", nil
+	}, WithLazyNoWait[string]())
+	defer strs.Close()
+
+	// Lazy caches become ready once loaded
+	ints.Get()
+	strs.Get()
+	if err := WarmAll(ctx, ints, strs); err != nil {
+		t.Errorf("WarmAll() = %v, want nil", err)
+	}
+
+	// A cache that never loads fails the warm up once the context is done
+	broken := New(ctx, time.Hour, func() (int, error) {
+		return 0, errors.New("update failed")
+	})
+	defer broken.Close()
+
+	warmCtx, warmCancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer warmCancel()
+	if err := WarmAll(warmCtx, ints, broken); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WarmAll() with a broken cache = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestWarmAllLazy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var loads atomic.Int64
+	waiting := New(ctx, time.Hour, func() (int, error) {
+		loads.Add(1)
+		return 1, nil
+	}, WithLazy[int]())
+	defer waiting.Close()
+	noWait := New(ctx, time.Hour, func() (string, error) {
+		loads.Add(1)
+		return "a", nil
+	}, WithLazyNoWait[string]())
+	defer noWait.Close()
+
+	// WarmAll loads lazy caches nobody has read yet
+	warmCtx, warmCancel := context.WithTimeout(ctx, time.Second)
+	defer warmCancel()
+	if err := WarmAll(warmCtx, waiting, noWait); err != nil {
+		t.Errorf("WarmAll() of lazy caches = %v, want nil", err)
+	}
+	if got := waiting.Get(); got != 1 {
+		t.Errorf("Get() after WarmAll() = %v, want %v", got, 1)
+	}
+
+	// Once each, also when waited for again
+	if err := WarmAll(warmCtx, waiting, noWait); err != nil {
+		t.Errorf("WarmAll() again = %v, want nil", err)
+	}
+	if got := loads.Load(); got != 2 {
+		t.Errorf("Loads = %v, want %v", got, 2)
+	}
+}